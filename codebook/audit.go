@@ -0,0 +1,127 @@
+package codebook
+
+import (
+	"encoding/binary"
+	"log"
+	"time"
+)
+
+// AuditKind identifies the source of an audit entry
+type AuditKind string
+
+// AuditOutcome is what happened as a result of an audited event
+type AuditOutcome string
+
+// AuditEntry is a single append-only record of an access attempt or door
+// transition
+type AuditEntry struct {
+	Time    time.Time
+	Kind    AuditKind
+	Name    string
+	Outcome AuditOutcome
+	Detail  string `json:",omitempty"`
+}
+
+// AuditQuery filters audit entries. Zero values match everything; To is
+// exclusive.
+type AuditQuery struct {
+	From    time.Time
+	To      time.Time
+	Kind    AuditKind
+	Name    string
+	Outcome AuditOutcome
+}
+
+const (
+	CodeAudit     AuditKind = "code"
+	TotpAudit     AuditKind = "totp"
+	OverrideAudit AuditKind = "override"
+	DoorAudit     AuditKind = "door"
+
+	Granted     AuditOutcome = "granted"
+	Denied      AuditOutcome = "denied"
+	Opened      AuditOutcome = "opened"
+	Closed      AuditOutcome = "closed"
+	NotOpened   AuditOutcome = "not_opened"
+	NotClosed   AuditOutcome = "not_closed"
+	Forced      AuditOutcome = "forced"
+	LatchFailed AuditOutcome = "latch_failed"
+
+	auditBucketName = "audit_log"
+)
+
+// Record appends an entry to the audit log
+func (cb *Codebook) Record(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	return cb.store.Record(entry)
+}
+
+// Audit records an entry at the given time, logging rather than returning any
+// failure so that callers on the door path are never interrupted
+func (cb *Codebook) Audit(now time.Time, kind AuditKind, name string, outcome AuditOutcome, detail string) {
+	err := cb.Record(AuditEntry{
+		Time:    now,
+		Kind:    kind,
+		Name:    name,
+		Outcome: outcome,
+		Detail:  detail,
+	})
+	if err != nil {
+		log.Printf("CODEBOOK: Error recording audit entry: %v, %v\n", kind, err)
+	}
+}
+
+// QueryAudit returns the matching audit entries in time order
+func (cb *Codebook) QueryAudit(q AuditQuery) ([]AuditEntry, error) {
+	return cb.store.QueryAudit(q)
+}
+
+// PruneAudit deletes entries older than the retention period
+func (cb *Codebook) PruneAudit(retention time.Duration, now time.Time) (int, error) {
+	return cb.store.PruneAudit(now.Add(-retention))
+}
+
+// PruneAuditEvery periodically prunes the audit log in the background
+func (cb *Codebook) PruneAuditEvery(retention, interval time.Duration) {
+	go func() {
+		for {
+			pruned, err := cb.PruneAudit(retention, time.Now())
+			if err != nil {
+				log.Printf("CODEBOOK: Error pruning audit log: %v\n", err)
+			} else if pruned > 0 {
+				log.Printf("CODEBOOK: Pruned %v audit entries\n", pruned)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func (q *AuditQuery) matches(entry *AuditEntry) bool {
+	if !q.From.IsZero() && entry.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !entry.Time.Before(q.To) {
+		return false
+	}
+	if q.Kind != "" && q.Kind != entry.Kind {
+		return false
+	}
+	if q.Name != "" && q.Name != entry.Name {
+		return false
+	}
+	if q.Outcome != "" && q.Outcome != entry.Outcome {
+		return false
+	}
+	return true
+}
+
+// auditKey sorts entries by time, with the bucket sequence keeping entries
+// recorded at the same instant distinct
+func auditKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
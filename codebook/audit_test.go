@@ -0,0 +1,83 @@
+package codebook
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testStores(t *testing.T) map[string]Store {
+	bolt, err := OpenBoltStore(filepath.Join(t.TempDir(), "codebook.db"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	return map[string]Store{
+		"bolt":   bolt,
+		"memory": NewMemoryStore(),
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	for name, store := range testStores(t) {
+		book := New(store, "123456", "999999", 0, 0)
+		defer book.Close()
+
+		now, _ := time.Parse(ISO8601, "2017-11-09T11:10:03+0000")
+		book.Audit(now.Add(-2*time.Hour), CodeAudit, "DPD", Granted, "")
+		book.Audit(now.Add(-1*time.Hour), CodeAudit, "", Denied, "")
+		book.Audit(now.Add(-1*time.Hour), DoorAudit, "", Opened, "")
+		book.Audit(now, CodeAudit, "DPD", Denied, "")
+
+		all, err := book.QueryAudit(AuditQuery{})
+		if err != nil {
+			t.Errorf("%v: Expected no error, got %v\n", name, err)
+		}
+		if len(all) != 4 {
+			t.Fatalf("%v: Expected 4 entries, got %v\n", name, len(all))
+		}
+		if all[0].Name != "DPD" || all[0].Outcome != Granted {
+			t.Errorf("%v: Expected entries in time order, got %v\n", name, all[0])
+		}
+
+		dpd, _ := book.QueryAudit(AuditQuery{Name: "DPD"})
+		if len(dpd) != 2 {
+			t.Errorf("%v: Expected 2 entries, got %v\n", name, len(dpd))
+		}
+		denied, _ := book.QueryAudit(AuditQuery{Outcome: Denied})
+		if len(denied) != 2 {
+			t.Errorf("%v: Expected 2 entries, got %v\n", name, len(denied))
+		}
+		door, _ := book.QueryAudit(AuditQuery{Kind: DoorAudit})
+		if len(door) != 1 {
+			t.Errorf("%v: Expected 1 entry, got %v\n", name, len(door))
+		}
+		hour, _ := book.QueryAudit(AuditQuery{From: now.Add(-1 * time.Hour), To: now})
+		if len(hour) != 2 {
+			t.Errorf("%v: Expected 2 entries, got %v\n", name, len(hour))
+		}
+	}
+}
+
+func TestAuditPrune(t *testing.T) {
+	for name, store := range testStores(t) {
+		book := New(store, "123456", "999999", 0, 0)
+		defer book.Close()
+
+		now, _ := time.Parse(ISO8601, "2017-11-09T11:10:03+0000")
+		book.Audit(now.Add(-48*time.Hour), CodeAudit, "Old", Granted, "")
+		book.Audit(now.Add(-25*time.Hour), CodeAudit, "Old", Granted, "")
+		book.Audit(now.Add(-1*time.Hour), CodeAudit, "New", Granted, "")
+
+		pruned, err := book.PruneAudit(24*time.Hour, now)
+		if err != nil {
+			t.Errorf("%v: Expected no error, got %v\n", name, err)
+		}
+		if pruned != 2 {
+			t.Errorf("%v: Expected 2 pruned, got %v\n", name, pruned)
+		}
+		remaining, _ := book.QueryAudit(AuditQuery{})
+		if len(remaining) != 1 || remaining[0].Name != "New" {
+			t.Errorf("%v: Expected only the new entry, got %v\n", name, remaining)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package codebook
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/teabot/parceldrop/sms"
@@ -37,6 +41,8 @@ type AccessCode struct {
 	Days      []Day
 	StartTime IsoTime
 	EndTime   IsoTime
+	// Version is incremented by the store on every write
+	Version int
 }
 
 const (
@@ -46,95 +52,120 @@ const (
 	Count     CodeType = "count"
 	DayFilter CodeType = "day"
 	Silent    CodeType = "silent"
+	// Duress opens the door as normal while raising an alarm
+	Duress CodeType = "duress"
 
 	ISO8601 = "2006-01-02T15:04:05-0700"
 )
 
-var masterCode string
-var start, end time.Duration
+// ErrVersionConflict is returned when an update was made against a code that
+// has since changed
+var ErrVersionConflict = errors.New("access code version conflict")
 
-func Initialise(codebookPath, adminCode, defaultCode string, dayStart, dayEnd time.Duration) error {
-	if len(adminCode) > 0 {
-		masterCode = adminCode
-	} else {
-		masterCode = defaultCode
-	}
+// Codebook checks entered digits against the access codes held in a Store
+type Codebook struct {
+	store      Store
+	masterCode string
+	start, end time.Duration
 
-	start = dayStart
-	end = dayEnd
+	// mu serialises checks with the commits and releases of reservations
+	mu       sync.Mutex
+	reserved map[string]int
+}
 
-	err := OpenStore(codebookPath)
-	if err != nil {
-		return err
+// New creates a Codebook over the store. The admin code falls back to the
+// default code when empty, and dayStart/dayEnd form the window for day codes
+// without one of their own.
+func New(store Store, adminCode, defaultCode string, dayStart, dayEnd time.Duration) *Codebook {
+	masterCode := adminCode
+	if len(masterCode) == 0 {
+		masterCode = defaultCode
+	}
+	return &Codebook{
+		store:      store,
+		masterCode: masterCode,
+		start:      dayStart,
+		end:        dayEnd,
+		reserved:   map[string]int{},
 	}
-	return nil
 }
 
-func Check(digits string, now time.Time) (bool, bool, string) {
-	if digits == masterCode {
+// Check validates the digits and, if they are accepted, reserves a use of the
+// code. The use only counts once the reservation is committed, when the door
+// is seen to open, and is returned if it is released instead. The admin code
+// has no reservation.
+func (cb *Codebook) Check(digits string, now time.Time) Result {
+	if cb.isMaster(digits) {
 		log.Printf("CODEBOOK: Matched admin code: %v\n", digits)
-		return true, true, "Master"
+		return Result{Valid: true, Reason: Accepted, Name: "Master", Master: true, Notify: NotifySilent}
 	}
-	code, _ := GetAccessCode(digits)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	code, _ := cb.store.Get(digits)
 	if code == nil {
 		log.Printf("CODEBOOK: Code not found: %v\n", digits)
-		return false, false, ""
-	}
-	if !code.hasType(Active) {
-		log.Printf("CODEBOOK: Code not active: %v\n", digits)
-		return false, false, code.Name
-	}
-	if code.hasType(Count) && (code.Usage < 0 || code.MaxUsage < 1 || code.Usage >= code.MaxUsage) {
-		log.Printf("CODEBOOK: Usage exceeded: %v, %v>=%v\n", digits, code.Usage, code.MaxUsage)
-		return false, false, code.Name
-	}
+		return Result{Reason: NotFound}
+	}
+	result := Result{
+		Reason: cb.evaluate(code, now, cb.reserved[digits]),
+		Name:   code.Name,
+		Notify: code.notify(),
+		Alarm:  code.hasType(Duress),
+	}
+	if result.Reason != Accepted {
+		return result
+	}
+	result.Expiring = code.expiring(now, cb.reserved[digits])
+	cb.reserved[digits]++
+	result.Valid = true
+	result.Reservation = &Reservation{
+		book:   cb,
+		digits: digits,
+		at:     now,
+	}
+	return result
+}
 
-	nowStr := now.Format(ISO8601)
-	if code.hasType(Duration) && code.Usage > 0 {
-		firstUse, err := time.Parse(ISO8601, string(code.FirstUse))
-		if err != nil {
-			log.Printf("CODEBOOK: Duration: Invalid first use: %v, %v\n", digits, code.FirstUse)
-			return false, false, code.Name
-		}
-		duration := time.Duration(code.ValidityHours) * time.Hour
-		if firstUse.Add(duration).Before(now) {
-			log.Printf("CODEBOOK: Duration expired: %v, %v, %vhrs\n", digits, code.FirstUse, code.ValidityHours)
-			return false, false, code.Name
-		}
-	}
-	if code.hasType(Interval) {
-		from, err := time.Parse(ISO8601, string(code.ValidFrom))
-		if err != nil {
-			log.Printf("CODEBOOK: Interval: Invalid from: %v, %v\n", digits, code.ValidFrom)
-			return false, false, code.Name
-		}
-		to, err := time.Parse(ISO8601, string(code.Expiration))
-		if err != nil {
-			log.Printf("CODEBOOK: Interval: Invalid to: %v, %v\n", digits, code.Expiration)
-			return false, false, code.Name
-		}
-		if now.Before(from) || now.After(to) {
-			log.Printf("CODEBOOK: Outside of interval: %v, %v -> %v\n", digits, code.ValidFrom, code.Expiration)
-			return false, false, code.Name
-		}
-	}
-	if code.hasType(DayFilter) {
-		nowDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		if now.Before(nowDay.Add(start)) || now.After(nowDay.Add(end)) {
-			log.Printf("CODEBOOK: Outside of day pattern: %v, (%v -> %v @ %v)\n", digits, start, end, now)
-			return false, false, code.Name
-		}
+// IsAdmin reports whether the digits are the admin code
+func (cb *Codebook) IsAdmin(digits string) bool {
+	return cb.isMaster(digits)
+}
+
+func (cb *Codebook) isMaster(digits string) bool {
+	return subtle.ConstantTimeCompare([]byte(digits), []byte(cb.masterCode)) == 1
+}
+
+// Get returns the code for the digits, or nil if there is none
+func (cb *Codebook) Get(digits string) (*AccessCode, error) {
+	return cb.store.Get(digits)
+}
+
+// List prints every stored code
+func (cb *Codebook) List() error {
+	codes, err := cb.store.List()
+	if err != nil {
+		return err
 	}
-	if code.Usage == 0 {
-		code.FirstUse = IsoTimestamp(nowStr)
+	for _, code := range codes {
+		fmt.Printf("CODEBOOK: %v, %v\n", code.Name, code.Types)
 	}
-	code.Usage++
-	code.save()
-	return true, code.hasType(Silent), code.Name
+	return nil
 }
 
-func Close() {
-	CloseStore()
+func (cb *Codebook) Close() error {
+	return cb.store.Close()
+}
+
+// evaluate applies the rules for each of the code's types at the given time,
+// counting uses that are reserved but not yet committed
+func (cb *Codebook) evaluate(code *AccessCode, now time.Time, reserved int) Reason {
+	for _, rule := range cb.rules(code, now, reserved) {
+		if !rule.Passed {
+			log.Printf("CODEBOOK: Rule %v failed: %v, %v\n", rule.Name, code.Digits, rule.Detail)
+			return rule.Reason
+		}
+	}
+	return Accepted
 }
 
 func (c *AccessCode) hasType(ct CodeType) bool {
@@ -146,34 +177,66 @@ func (c *AccessCode) hasType(ct CodeType) bool {
 	return false
 }
 
-func Rescind(digits *string) error {
+func (cb *Codebook) Rescind(digits *string) error {
 	log.Printf("CODEBOOK: Rescinding code: %v\n", digits)
-	code, err := GetAccessCode(*digits)
+	rescinded := false
+	_, err := cb.store.Modify(*digits, func(code *AccessCode) (*AccessCode, error) {
+		if code == nil {
+			log.Printf("CODEBOOK: No code to rescind: %v", digits)
+			return nil, nil
+		}
+		if !code.hasType(Active) {
+			log.Printf("CODEBOOK: Code already rescinded: %v", code)
+			return nil, nil
+		}
+		code.Types = remove(code.Types, Active)
+		rescinded = true
+		return code, nil
+	})
 	if err != nil {
-		log.Printf("CODEBOOK: Error getting code: %v", digits)
+		log.Printf("CODEBOOK: Error saving rescinded code: %v, %v", digits, err)
 		return err
 	}
-	if code == nil {
-		log.Printf("CODEBOOK: No code to rescind: %v", digits)
-		return nil
-	}
-	if !code.hasType(Active) {
-		log.Printf("CODEBOOK: Code already rescinded: %v", code)
-		return nil
-	}
-	code.Types = remove(code.Types, Active)
-	err2 := code.save()
-	if err2 != nil {
-		log.Printf("CODEBOOK: Error saving rescinded code: %v, %v", code, err2)
-		return err
+	if rescinded {
+		sms.SendRescindedCode(digits)
 	}
-	sms.SendRescindedCode(digits)
 	return nil
 }
 
-func Update(code *AccessCode) error {
+// Update creates or replaces a code. An update carrying the Version of the
+// stored code replaces it outright, while one without a Version keeps the
+// stored Usage and FirstUse, so that codes in use are not reset, unless it sets
+// either of them. An update made against an older Version is rejected with
+// ErrVersionConflict.
+func (cb *Codebook) Update(code *AccessCode) error {
+	return cb.update(code, code.Usage != 0 || code.FirstUse != "")
+}
+
+// Replace is Update taking the code's Usage and FirstUse as they are, even if
+// unset, so that a used code can be started afresh without knowing its Version
+func (cb *Codebook) Replace(code *AccessCode) error {
+	return cb.update(code, true)
+}
+
+func (cb *Codebook) update(code *AccessCode, setsUsage bool) error {
 	log.Printf("CODEBOOK: Updating code: %v\n", code)
-	err := code.save()
+	_, err := cb.store.Modify(code.Digits, func(current *AccessCode) (*AccessCode, error) {
+		updated := code.clone()
+		if current == nil {
+			return updated, nil
+		}
+		if updated.Version == 0 {
+			if !setsUsage {
+				updated.Usage = current.Usage
+				updated.FirstUse = current.FirstUse
+			}
+			return updated, nil
+		}
+		if updated.Version != current.Version {
+			return nil, fmt.Errorf("%w: %v, %v!=%v", ErrVersionConflict, code.Name, code.Version, current.Version)
+		}
+		return updated, nil
+	})
 	if err == nil {
 		sms.SendUpdatedCode(&code.Name, &code.Digits)
 	}
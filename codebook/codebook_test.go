@@ -1,6 +1,10 @@
 package codebook
 
 import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -8,19 +12,16 @@ import (
 func TestMaster(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
-	check, silent, name := Check("123456", time.Now().UTC())
-	if !check {
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+	result := book.Check("123456", time.Now().UTC())
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
-	if !silent {
+	if result.Notify != NotifySilent {
 		t.Errorf("Expected silent\n")
 	}
-	if name != "Master" {
+	if result.Name != "Master" {
 		t.Errorf("Expected check to succeed\n")
 	}
 }
@@ -28,19 +29,16 @@ func TestMaster(t *testing.T) {
 func TestDefault(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
-	check, silent, name := Check("999999", time.Now().UTC())
-	if !check {
+	book := New(NewMemoryStore(), "", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+	result := book.Check("999999", time.Now().UTC())
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
-	if !silent {
+	if result.Notify != NotifySilent {
 		t.Errorf("Expected silent\n")
 	}
-	if name != "Master" {
+	if result.Name != "Master" {
 		t.Errorf("Expected check to succeed\n")
 	}
 }
@@ -48,16 +46,13 @@ func TestDefault(t *testing.T) {
 func TestUnknown(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
-	check, _, name := Check("5555", time.Now().UTC())
-	if check {
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+	result := book.Check("5555", time.Now().UTC())
+	if result.Valid {
 		t.Errorf("Expected check to fail\n")
 	}
-	if name != "" {
+	if result.Name != "" {
 		t.Errorf("Expected check to fail\n")
 	}
 }
@@ -65,11 +60,8 @@ func TestUnknown(t *testing.T) {
 func TestValidCount(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:    []CodeType{"active", "count"},
@@ -78,14 +70,15 @@ func TestValidCount(t *testing.T) {
 		MaxUsage: 3,
 		Usage:    1,
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
-	if !check {
+	result := book.Check("6789", time.Now().UTC())
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
+	result.Reservation.Commit()
 
-	actual, _ := GetAccessCode("6789")
+	actual, _ := book.Get("6789")
 	if actual.Usage != 2 {
 		t.Errorf("Expected usage to be 2\n")
 	}
@@ -94,24 +87,21 @@ func TestValidCount(t *testing.T) {
 func TestSilent(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:  []CodeType{"active", "silent"},
 		Name:   "Test",
 		Digits: "6789",
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, silent, _ := Check("6789", time.Now().UTC())
-	if !check {
+	result := book.Check("6789", time.Now().UTC())
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
-	if !silent {
+	if result.Notify != NotifySilent {
 		t.Errorf("Expected silent\n")
 	}
 }
@@ -119,24 +109,21 @@ func TestSilent(t *testing.T) {
 func TestNotSilent(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:  []CodeType{"active"},
 		Name:   "Test",
 		Digits: "6789",
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, silent, _ := Check("6789", time.Now().UTC())
-	if !check {
+	result := book.Check("6789", time.Now().UTC())
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
-	if silent {
+	if result.Notify == NotifySilent {
 		t.Errorf("Expected not silent\n")
 	}
 }
@@ -144,11 +131,8 @@ func TestNotSilent(t *testing.T) {
 func TestValidCountFirstUse(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:    []CodeType{"active", "count"},
@@ -157,15 +141,16 @@ func TestValidCountFirstUse(t *testing.T) {
 		MaxUsage: 3,
 		Usage:    0,
 	}
-	code.save()
+	book.store.Put(&code)
 
 	now := time.Now().UTC()
-	check, _, _ := Check("6789", now)
-	if !check {
+	result := book.Check("6789", now)
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
+	result.Reservation.Commit()
 
-	actual, _ := GetAccessCode("6789")
+	actual, _ := book.Get("6789")
 	if actual.Usage != 1 {
 		t.Errorf("Expected usage to be 1\n")
 	}
@@ -177,11 +162,8 @@ func TestValidCountFirstUse(t *testing.T) {
 func TestInvalidCount(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:    []CodeType{"active", "count"},
@@ -190,9 +172,9 @@ func TestInvalidCount(t *testing.T) {
 		MaxUsage: 3,
 		Usage:    4,
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -201,20 +183,17 @@ func TestInvalidCount(t *testing.T) {
 func TestInvalidType(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:  []CodeType{},
 		Name:   "Test",
 		Digits: "6789",
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -223,20 +202,17 @@ func TestInvalidType(t *testing.T) {
 func TestInactiveType(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:  []CodeType{"count"},
 		Name:   "Test",
 		Digits: "6789",
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -245,11 +221,8 @@ func TestInactiveType(t *testing.T) {
 func TestDurationInvalidFirstUse(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:    []CodeType{"active", "duration"},
@@ -258,9 +231,9 @@ func TestDurationInvalidFirstUse(t *testing.T) {
 		FirstUse: "jsjsjsjsj",
 		Usage:    1,
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -269,11 +242,8 @@ func TestDurationInvalidFirstUse(t *testing.T) {
 func TestDurationExpired(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	now := time.Now().UTC()
 	code := AccessCode{
@@ -284,9 +254,9 @@ func TestDurationExpired(t *testing.T) {
 		ValidityHours: 1,
 		Usage:         1,
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -295,11 +265,8 @@ func TestDurationExpired(t *testing.T) {
 func TestDurationOk(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	now := time.Now().UTC()
 	code := AccessCode{
@@ -310,14 +277,15 @@ func TestDurationOk(t *testing.T) {
 		ValidityHours: 2,
 		Usage:         1,
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
-	if !check {
+	result := book.Check("6789", time.Now().UTC())
+	if !result.Valid {
 		t.Errorf("Expected check to succeed\n")
 	}
+	result.Reservation.Commit()
 
-	actual, _ := GetAccessCode("6789")
+	actual, _ := book.Get("6789")
 	if actual.Usage != 2 {
 		t.Errorf("Expected usage to be 2\n")
 	}
@@ -326,11 +294,8 @@ func TestDurationOk(t *testing.T) {
 func TestIntervalInvalidFrom(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	now := time.Now().UTC()
 	code := AccessCode{
@@ -340,9 +305,9 @@ func TestIntervalInvalidFrom(t *testing.T) {
 		ValidFrom:  "jsjsjsjsj",
 		Expiration: IsoTimestamp(now.Add(1 * time.Hour).Format(ISO8601)),
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -351,11 +316,8 @@ func TestIntervalInvalidFrom(t *testing.T) {
 func TestIntervalInvalidTo(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	now := time.Now().UTC()
 	code := AccessCode{
@@ -365,9 +327,9 @@ func TestIntervalInvalidTo(t *testing.T) {
 		Expiration: "jsjsjsjsj",
 		ValidFrom:  IsoTimestamp(now.Add(-1 * time.Hour).Format(ISO8601)),
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", time.Now().UTC())
+	check := book.Check("6789", time.Now().UTC()).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -376,11 +338,8 @@ func TestIntervalInvalidTo(t *testing.T) {
 func TestIntervalOutside(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	now := time.Now().UTC()
 	code := AccessCode{
@@ -390,13 +349,13 @@ func TestIntervalOutside(t *testing.T) {
 		ValidFrom:  IsoTimestamp(now.Add(-1 * time.Hour).Format(ISO8601)),
 		Expiration: IsoTimestamp(now.Add(1 * time.Hour).Format(ISO8601)),
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", now.Add(2*time.Hour))
+	check := book.Check("6789", now.Add(2*time.Hour)).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
-	check, _, _ = Check("6789", now.Add(-2*time.Hour))
+	check = book.Check("6789", now.Add(-2*time.Hour)).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
@@ -405,11 +364,8 @@ func TestIntervalOutside(t *testing.T) {
 func TestIntervalInside(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	now := time.Now().UTC()
 	code := AccessCode{
@@ -419,9 +375,9 @@ func TestIntervalInside(t *testing.T) {
 		ValidFrom:  IsoTimestamp(now.Add(-1 * time.Hour).Format(ISO8601)),
 		Expiration: IsoTimestamp(now.Add(1 * time.Hour).Format(ISO8601)),
 	}
-	code.save()
+	book.store.Put(&code)
 
-	check, _, _ := Check("6789", now)
+	check := book.Check("6789", now).Valid
 	if !check {
 		t.Errorf("Expected check to succeed\n")
 	}
@@ -430,11 +386,8 @@ func TestIntervalInside(t *testing.T) {
 func TestDayInside(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:    []CodeType{"active", "count", "day"},
@@ -443,10 +396,10 @@ func TestDayInside(t *testing.T) {
 		Usage:    0,
 		MaxUsage: 1,
 	}
-	code.save()
+	book.store.Put(&code)
 
 	now, _ := time.Parse(ISO8601, "2017-11-09T11:10:03+0000")
-	check, _, _ := Check("6789", now)
+	check := book.Check("6789", now).Valid
 	if !check {
 		t.Errorf("Expected check to succeed\n")
 	}
@@ -455,11 +408,8 @@ func TestDayInside(t *testing.T) {
 func TestDayOutside(t *testing.T) {
 	duration6h30m, _ := time.ParseDuration("6h30m")
 	duration21h30m, _ := time.ParseDuration("21h30m")
-	err := Initialise("", "123456", "999999", duration6h30m, duration21h30m)
-	defer Close()
-	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
-	}
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
 
 	code := AccessCode{
 		Types:    []CodeType{"active", "count", "day"},
@@ -468,16 +418,369 @@ func TestDayOutside(t *testing.T) {
 		Usage:    0,
 		MaxUsage: 1,
 	}
-	code.save()
+	book.store.Put(&code)
 
 	now1, _ := time.Parse(ISO8601, "2017-11-09T06:29:03+0000")
-	check, _, _ := Check("6789", now1)
+	check := book.Check("6789", now1).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
 	now2, _ := time.Parse(ISO8601, "2017-11-09T21:31:03+0000")
-	check, _, _ = Check("6789", now2)
+	check = book.Check("6789", now2).Valid
 	if check {
 		t.Errorf("Expected check to fail\n")
 	}
 }
+
+func TestDayOwnWindow(t *testing.T) {
+	duration6h30m, _ := time.ParseDuration("6h30m")
+	duration21h30m, _ := time.ParseDuration("21h30m")
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:     []CodeType{"active", "day"},
+		Name:      "Cleaner",
+		Digits:    "6789",
+		Days:      []Day{Mon, Thu},
+		StartTime: "09:00",
+		EndTime:   "13:00",
+	}
+	book.store.Put(&code)
+
+	// Thursday
+	now, _ := time.Parse(ISO8601, "2017-11-09T09:00:00+0000")
+	if check := book.Check("6789", now).Valid; !check {
+		t.Errorf("Expected check to succeed at %v\n", now)
+	}
+	now, _ = time.Parse(ISO8601, "2017-11-09T13:00:00+0000")
+	if check := book.Check("6789", now).Valid; !check {
+		t.Errorf("Expected check to succeed at %v\n", now)
+	}
+	now, _ = time.Parse(ISO8601, "2017-11-09T08:59:59+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+	now, _ = time.Parse(ISO8601, "2017-11-09T13:00:01+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+	// Wednesday
+	now, _ = time.Parse(ISO8601, "2017-11-08T10:00:00+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+}
+
+func TestDayOwnDaysGlobalTimes(t *testing.T) {
+	duration6h30m, _ := time.ParseDuration("6h30m")
+	duration21h30m, _ := time.ParseDuration("21h30m")
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:  []CodeType{"active", "day"},
+		Name:   "Courier",
+		Digits: "6789",
+		Days:   []Day{"Monday", "tue", "Wed", "THURSDAY", "Fri"},
+	}
+	book.store.Put(&code)
+
+	// Friday
+	now, _ := time.Parse(ISO8601, "2017-11-10T06:31:00+0000")
+	if check := book.Check("6789", now).Valid; !check {
+		t.Errorf("Expected check to succeed at %v\n", now)
+	}
+	now, _ = time.Parse(ISO8601, "2017-11-10T06:29:00+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+	// Saturday
+	now, _ = time.Parse(ISO8601, "2017-11-11T12:00:00+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+}
+
+func TestDayOvernightWindow(t *testing.T) {
+	duration6h30m, _ := time.ParseDuration("6h30m")
+	duration21h30m, _ := time.ParseDuration("21h30m")
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:     []CodeType{"active", "day"},
+		Name:      "Night",
+		Digits:    "6789",
+		Days:      []Day{Fri},
+		StartTime: "22:00",
+		EndTime:   "02:00",
+	}
+	book.store.Put(&code)
+
+	// Friday night, into Saturday morning
+	now, _ := time.Parse(ISO8601, "2017-11-10T23:00:00+0000")
+	if check := book.Check("6789", now).Valid; !check {
+		t.Errorf("Expected check to succeed at %v\n", now)
+	}
+	now, _ = time.Parse(ISO8601, "2017-11-11T01:30:00+0000")
+	if check := book.Check("6789", now).Valid; !check {
+		t.Errorf("Expected check to succeed at %v\n", now)
+	}
+	// Friday morning belongs to Thursday night
+	now, _ = time.Parse(ISO8601, "2017-11-10T01:30:00+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+	now, _ = time.Parse(ISO8601, "2017-11-11T03:00:00+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail at %v\n", now)
+	}
+}
+
+func TestDayInvalidWindow(t *testing.T) {
+	duration6h30m, _ := time.ParseDuration("6h30m")
+	duration21h30m, _ := time.ParseDuration("21h30m")
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:     []CodeType{"active", "day"},
+		Name:      "Test",
+		Digits:    "6789",
+		Days:      []Day{"Funday"},
+		StartTime: "09:00",
+	}
+	book.store.Put(&code)
+
+	now, _ := time.Parse(ISO8601, "2017-11-09T11:10:03+0000")
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail\n")
+	}
+
+	for _, day := range []Day{"Thurs", "Thursdayz", "Th"} {
+		code.Days = []Day{day}
+		book.store.Put(&code)
+		if check := book.Check("6789", now).Valid; check {
+			t.Errorf("Expected check to fail for %v\n", day)
+		}
+	}
+
+	code.Days = nil
+	code.StartTime = "9am"
+	book.store.Put(&code)
+	if check := book.Check("6789", now).Valid; check {
+		t.Errorf("Expected check to fail\n")
+	}
+}
+
+func TestConcurrentCount(t *testing.T) {
+	for name, store := range testStores(t) {
+		book := New(store, "123456", "999999", 0, 0)
+		defer book.Close()
+
+		code := AccessCode{
+			Types:    []CodeType{"active", "count"},
+			Name:     "Test",
+			Digits:   "6789",
+			MaxUsage: 5,
+		}
+		book.store.Put(&code)
+
+		var wg sync.WaitGroup
+		var granted int32
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if result := book.Check("6789", time.Now().UTC()); result.Valid {
+					atomic.AddInt32(&granted, 1)
+					result.Reservation.Commit()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if granted != 5 {
+			t.Errorf("%v: Expected 5 checks to succeed, got %v\n", name, granted)
+		}
+		actual, _ := book.Get("6789")
+		if actual.Usage != 5 {
+			t.Errorf("%v: Expected usage to be 5, got %v\n", name, actual.Usage)
+		}
+	}
+}
+
+func TestUpdateKeepsUsage(t *testing.T) {
+	for name, store := range testStores(t) {
+		book := New(store, "123456", "999999", 0, 0)
+		defer book.Close()
+
+		code := AccessCode{
+			Types:    []CodeType{"active", "count"},
+			Name:     "Test",
+			Digits:   "6789",
+			MaxUsage: 3,
+		}
+		book.store.Put(&code)
+		first := book.Check("6789", time.Now().UTC()).Reservation
+		second := book.Check("6789", time.Now().UTC()).Reservation
+		first.Commit()
+
+		second.Commit()
+		err := book.Update(&AccessCode{
+			Types:    []CodeType{"active", "count"},
+			Name:     "Renamed",
+			Digits:   "6789",
+			MaxUsage: 4,
+		})
+		if err != nil {
+			t.Errorf("%v: Expected no error, got %v\n", name, err)
+		}
+		actual, _ := book.Get("6789")
+		if actual.Usage != 2 || actual.MaxUsage != 4 || actual.Name != "Renamed" {
+			t.Errorf("%v: Expected merged code, got %v\n", name, actual)
+		}
+
+		book.Update(&AccessCode{Types: []CodeType{"active", "count"}, Name: "Renamed", Digits: "6789", MaxUsage: 4, Usage: 1})
+		if actual, _ = book.Get("6789"); actual.Usage != 1 {
+			t.Errorf("%v: Expected usage set by the update, got %v\n", name, actual)
+		}
+		book.Replace(&AccessCode{Types: []CodeType{"active", "count"}, Name: "Renamed", Digits: "6789", MaxUsage: 4})
+		if actual, _ = book.Get("6789"); actual.Usage != 0 {
+			t.Errorf("%v: Expected usage reset by replace, got %v\n", name, actual)
+		}
+	}
+}
+
+func TestUpdateVersion(t *testing.T) {
+	for name, store := range testStores(t) {
+		book := New(store, "123456", "999999", 0, 0)
+		defer book.Close()
+
+		code := AccessCode{
+			Types:    []CodeType{"active", "count"},
+			Name:     "Test",
+			Digits:   "6789",
+			MaxUsage: 3,
+		}
+		book.store.Put(&code)
+		read, _ := book.Get("6789")
+		reservation := book.Check("6789", time.Now().UTC()).Reservation
+		reservation.Commit()
+
+		read.Usage = 0
+		err := book.Update(read)
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("%v: Expected version conflict, got %v\n", name, err)
+		}
+
+		current, _ := book.Get("6789")
+		current.Usage = 0
+		err = book.Update(current)
+		if err != nil {
+			t.Errorf("%v: Expected no error, got %v\n", name, err)
+		}
+		actual, _ := book.Get("6789")
+		if actual.Usage != 0 || actual.Version != current.Version+1 {
+			t.Errorf("%v: Expected reset usage at next version, got %v\n", name, actual)
+		}
+	}
+}
+
+func TestReasons(t *testing.T) {
+	duration6h30m, _ := time.ParseDuration("6h30m")
+	duration21h30m, _ := time.ParseDuration("21h30m")
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+
+	now, _ := time.Parse(ISO8601, "2017-11-09T05:10:03+0000")
+	codes := map[Reason]AccessCode{
+		Rescinded:       {Types: []CodeType{"count"}, MaxUsage: 1},
+		UsageExceeded:   {Types: []CodeType{"active", "count"}, MaxUsage: 1, Usage: 1},
+		DurationExpired: {Types: []CodeType{"active", "duration"}, Usage: 1, ValidityHours: 1, FirstUse: "2017-11-09T03:10:03+0000"},
+		OutsideInterval: {Types: []CodeType{"active", "interval"}, ValidFrom: "2017-11-10T00:00:00+0000", Expiration: "2017-11-11T00:00:00+0000"},
+		OutsideDay:      {Types: []CodeType{"active", "day"}},
+		Misconfigured:   {Types: []CodeType{"active", "interval"}, ValidFrom: "jsjsjsjsj"},
+		Accepted:        {Types: []CodeType{"active", "silent"}},
+	}
+	digits := 1000
+	for reason, code := range codes {
+		digits++
+		code.Digits = strconv.Itoa(digits)
+		code.Name = string(reason)
+		book.store.Put(&code)
+
+		result := book.Check(code.Digits, now)
+		if result.Reason != reason {
+			t.Errorf("Expected reason %v, got %v\n", reason, result.Reason)
+		}
+		if result.Valid != (reason == Accepted) {
+			t.Errorf("Expected %v to be valid only if accepted\n", reason)
+		}
+		if result.Name != code.Name {
+			t.Errorf("Expected name %v, got %v\n", code.Name, result.Name)
+		}
+		if reason == Accepted && result.Notify != NotifySilent {
+			t.Errorf("Expected silent notification, got %v\n", result.Notify)
+		}
+	}
+
+	result := book.Check("5555", now)
+	if result.Reason != NotFound || result.Name != "" {
+		t.Errorf("Expected code not to be found, got %v\n", result)
+	}
+}
+
+func TestDuress(t *testing.T) {
+	book := New(NewMemoryStore(), "123456", "999999", 0, 0)
+	defer book.Close()
+
+	now, _ := time.Parse(ISO8601, "2017-11-09T05:10:03+0000")
+	book.store.Put(&AccessCode{Digits: "4321", Name: "Duress", Types: []CodeType{"active", "duress"}})
+	book.store.Put(&AccessCode{Digits: "1234", Name: "Normal", Types: []CodeType{"active"}})
+
+	result := book.Check("4321", now)
+	if !result.Valid || !result.Alarm || result.Notify != NotifyNormal {
+		t.Errorf("Expected duress code to open normally and raise an alarm, got %v\n", result)
+	}
+	result = book.Check("1234", now)
+	if !result.Valid || result.Alarm {
+		t.Errorf("Expected normal code to raise no alarm, got %v\n", result)
+	}
+}
+
+func TestExpiring(t *testing.T) {
+	book := New(NewMemoryStore(), "123456", "999999", 0, 0)
+	defer book.Close()
+
+	now, _ := time.Parse(ISO8601, "2017-11-09T05:10:03+0000")
+	codes := map[string]AccessCode{
+		"1001": {Types: []CodeType{"active", "count"}, MaxUsage: 2},
+		"1002": {Types: []CodeType{"active", "interval"}, ValidFrom: "2017-11-01T00:00:00+0000", Expiration: "2017-11-09T12:00:00+0000"},
+		"1003": {Types: []CodeType{"active", "duration"}, Usage: 1, ValidityHours: 24, FirstUse: "2017-11-09T03:10:03+0000"},
+		"1004": {Types: []CodeType{"active", "interval"}, ValidFrom: "2017-11-01T00:00:00+0000", Expiration: "2017-11-12T00:00:00+0000"},
+		"1005": {Types: []CodeType{"active", "duration"}, ValidityHours: 1},
+	}
+	for digits, code := range codes {
+		code.Digits = digits
+		book.store.Put(&code)
+	}
+
+	if book.Check("1001", now).Expiring {
+		t.Errorf("Expected first of two uses not to be expiring\n")
+	}
+	if !book.Check("1001", now).Expiring {
+		t.Errorf("Expected last use to be expiring\n")
+	}
+	for _, digits := range []string{"1002", "1003"} {
+		if !book.Check(digits, now).Expiring {
+			t.Errorf("Expected %v to be expiring\n", digits)
+		}
+	}
+	for _, digits := range []string{"1004", "1005"} {
+		if book.Check(digits, now).Expiring {
+			t.Errorf("Expected %v not to be expiring\n", digits)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package codebook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	Mon Day = "Mon"
+	Tue Day = "Tue"
+	Wed Day = "Wed"
+	Thu Day = "Thu"
+	Fri Day = "Fri"
+	Sat Day = "Sat"
+	Sun Day = "Sun"
+)
+
+// weekdays accepts each day's full name or its three letter abbreviation
+var weekdays = map[string]time.Weekday{
+	"mon":       time.Monday,
+	"monday":    time.Monday,
+	"tue":       time.Tuesday,
+	"tuesday":   time.Tuesday,
+	"wed":       time.Wednesday,
+	"wednesday": time.Wednesday,
+	"thu":       time.Thursday,
+	"thursday":  time.Thursday,
+	"fri":       time.Friday,
+	"friday":    time.Friday,
+	"sat":       time.Saturday,
+	"saturday":  time.Saturday,
+	"sun":       time.Sunday,
+	"sunday":    time.Sunday,
+}
+
+var isoTimeLayouts = []string{"15:04", "15:04:05"}
+
+// insideDayWindow evaluates the code's own weekday and time of day window,
+// falling back to the global dayStart/dayEnd window for any part the code
+// does not specify. A window whose end is before its start wraps midnight and
+// belongs to the day on which it starts.
+func (c *AccessCode) insideDayWindow(now time.Time, dayStart, dayEnd time.Duration) (bool, error) {
+	days, err := parseDays(c.Days)
+	if err != nil {
+		return false, err
+	}
+	from, to := dayStart, dayEnd
+	if c.StartTime != "" || c.EndTime != "" {
+		from, to = 0, 24*time.Hour
+		if c.StartTime != "" {
+			if from, err = c.StartTime.offset(); err != nil {
+				return false, err
+			}
+		}
+		if c.EndTime != "" {
+			if to, err = c.EndTime.offset(); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	clock := sinceMidnight(now)
+	if from <= to {
+		return days.includes(now.Weekday()) && clock >= from && clock <= to, nil
+	}
+	if clock >= from {
+		return days.includes(now.Weekday()), nil
+	}
+	if clock <= to {
+		return days.includes(now.AddDate(0, 0, -1).Weekday()), nil
+	}
+	return false, nil
+}
+
+// offset returns the time of day as a duration since midnight
+func (t IsoTime) offset() (time.Duration, error) {
+	for _, layout := range isoTimeLayouts {
+		parsed, err := time.Parse(layout, string(t))
+		if err == nil {
+			return sinceMidnight(parsed), nil
+		}
+	}
+	if t == "24:00" || t == "24:00:00" {
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid time of day: %q", t)
+}
+
+// sinceMidnight measures the wall clock rather than elapsed time, so that a
+// window is unaffected by daylight saving changes
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+}
+
+type daySet map[time.Weekday]bool
+
+func parseDays(days []Day) (daySet, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+	set := daySet{}
+	for _, d := range days {
+		weekday, ok := weekdays[strings.ToLower(string(d))]
+		if !ok {
+			return nil, fmt.Errorf("invalid day: %q", d)
+		}
+		set[weekday] = true
+	}
+	return set, nil
+}
+
+// includes treats an empty set as every day of the week
+func (s daySet) includes(d time.Weekday) bool {
+	return len(s) == 0 || s[d]
+}
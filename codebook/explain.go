@@ -0,0 +1,211 @@
+package codebook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule is the outcome of applying one of a code's rules
+type Rule struct {
+	Name   string
+	Passed bool
+	// Reason the code is denied if the rule failed
+	Reason Reason
+	Detail string
+}
+
+// Explanation sets out how a code would be decided at a given time, without
+// using the code
+type Explanation struct {
+	At     time.Time
+	Name   string
+	Valid  bool
+	Reason Reason
+	Rules  []Rule
+}
+
+// Explain evaluates the digits at the given time with the same rules as
+// Check, listing every rule that passed or failed. No use is reserved.
+func (cb *Codebook) Explain(digits string, at time.Time) (Explanation, error) {
+	if cb.isMaster(digits) {
+		return Explanation{At: at, Name: "Master", Valid: true, Reason: Accepted, Rules: []Rule{
+			{Name: "admin", Passed: true, Detail: "admin code is always accepted"},
+		}}, nil
+	}
+	code, err := cb.store.Get(digits)
+	if err != nil {
+		return Explanation{}, err
+	}
+	if code == nil {
+		return Explanation{At: at, Reason: NotFound}, nil
+	}
+	return cb.ExplainCode(code, at), nil
+}
+
+// ExplainCode evaluates a code, which need not be stored, at the given time
+func (cb *Codebook) ExplainCode(code *AccessCode, at time.Time) Explanation {
+	cb.mu.Lock()
+	reserved := cb.reserved[code.Digits]
+	cb.mu.Unlock()
+
+	e := Explanation{
+		At:     at,
+		Name:   code.Name,
+		Valid:  true,
+		Reason: Accepted,
+		Rules:  cb.rules(code, at, reserved),
+	}
+	for _, rule := range e.Rules {
+		if !rule.Passed {
+			e.Valid = false
+			e.Reason = rule.Reason
+			break
+		}
+	}
+	return e
+}
+
+func (e Explanation) String() string {
+	var b strings.Builder
+	decision := "accepted"
+	if !e.Valid {
+		decision = "denied (" + e.Reason.Describe() + ")"
+	}
+	name := e.Name
+	if name == "" {
+		name = "Unknown code"
+	}
+	fmt.Fprintf(&b, "%v at %v: %v", name, e.At.Format(ISO8601), decision)
+	for _, rule := range e.Rules {
+		result := "pass"
+		if !rule.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(&b, "\n%v %v: %v", result, rule.Name, rule.Detail)
+	}
+	return b.String()
+}
+
+// rules applies each of the rules for the code's types at the given time,
+// counting uses that are reserved but not yet committed
+func (cb *Codebook) rules(code *AccessCode, now time.Time, reserved int) []Rule {
+	rules := []Rule{activeRule(code)}
+	if code.hasType(Count) {
+		rules = append(rules, countRule(code, reserved))
+	}
+	if code.hasType(Duration) {
+		rules = append(rules, durationRule(code, now))
+	}
+	if code.hasType(Interval) {
+		rules = append(rules, intervalRule(code, now))
+	}
+	if code.hasType(DayFilter) {
+		rules = append(rules, cb.dayRule(code, now))
+	}
+	return rules
+}
+
+func activeRule(code *AccessCode) Rule {
+	if !code.hasType(Active) {
+		return Rule{Name: string(Active), Reason: Rescinded, Detail: "code is not active"}
+	}
+	return Rule{Name: string(Active), Passed: true, Detail: "code is active"}
+}
+
+func countRule(code *AccessCode, reserved int) Rule {
+	rule := Rule{
+		Name:   string(Count),
+		Passed: code.Usage >= 0 && code.MaxUsage >= 1 && code.Usage+reserved < code.MaxUsage,
+		Detail: fmt.Sprintf("used %v of %v", code.Usage, code.MaxUsage),
+	}
+	if reserved > 0 {
+		rule.Detail += fmt.Sprintf(", %v reserved", reserved)
+	}
+	if !rule.Passed {
+		rule.Reason = UsageExceeded
+	}
+	return rule
+}
+
+func durationRule(code *AccessCode, now time.Time) Rule {
+	rule := Rule{Name: string(Duration)}
+	if code.Usage <= 0 {
+		rule.Passed = true
+		rule.Detail = fmt.Sprintf("not yet used, valid for %vhrs from first use", code.ValidityHours)
+		return rule
+	}
+	firstUse, err := time.Parse(ISO8601, string(code.FirstUse))
+	if err != nil {
+		rule.Reason = Misconfigured
+		rule.Detail = fmt.Sprintf("invalid first use %q", code.FirstUse)
+		return rule
+	}
+	expiry := firstUse.Add(time.Duration(code.ValidityHours) * time.Hour)
+	rule.Passed = !expiry.Before(now)
+	rule.Detail = fmt.Sprintf("first used %v, expires %v", code.FirstUse, expiry.Format(ISO8601))
+	if !rule.Passed {
+		rule.Reason = DurationExpired
+	}
+	return rule
+}
+
+func intervalRule(code *AccessCode, now time.Time) Rule {
+	rule := Rule{Name: string(Interval)}
+	from, err := time.Parse(ISO8601, string(code.ValidFrom))
+	if err != nil {
+		rule.Reason = Misconfigured
+		rule.Detail = fmt.Sprintf("invalid from %q", code.ValidFrom)
+		return rule
+	}
+	to, err := time.Parse(ISO8601, string(code.Expiration))
+	if err != nil {
+		rule.Reason = Misconfigured
+		rule.Detail = fmt.Sprintf("invalid expiration %q", code.Expiration)
+		return rule
+	}
+	rule.Passed = !now.Before(from) && !now.After(to)
+	rule.Detail = fmt.Sprintf("%v -> %v", code.ValidFrom, code.Expiration)
+	if !rule.Passed {
+		rule.Reason = OutsideInterval
+	}
+	return rule
+}
+
+func (cb *Codebook) dayRule(code *AccessCode, now time.Time) Rule {
+	rule := Rule{Name: string(DayFilter)}
+	inside, err := code.insideDayWindow(now, cb.start, cb.end)
+	if err != nil {
+		rule.Reason = Misconfigured
+		rule.Detail = err.Error()
+		return rule
+	}
+	rule.Passed = inside
+	rule.Detail = cb.describeDayWindow(code)
+	if !rule.Passed {
+		rule.Reason = OutsideDay
+	}
+	return rule
+}
+
+func (cb *Codebook) describeDayWindow(code *AccessCode) string {
+	days := "every day"
+	if len(code.Days) > 0 {
+		names := make([]string, len(code.Days))
+		for i, d := range code.Days {
+			names[i] = string(d)
+		}
+		days = strings.Join(names, ",")
+	}
+	if code.StartTime != "" || code.EndTime != "" {
+		from, to := code.StartTime, code.EndTime
+		if from == "" {
+			from = "00:00"
+		}
+		if to == "" {
+			to = "24:00"
+		}
+		return fmt.Sprintf("%v %v -> %v", days, from, to)
+	}
+	return fmt.Sprintf("%v %v -> %v", days, cb.start, cb.end)
+}
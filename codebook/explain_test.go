@@ -0,0 +1,88 @@
+package codebook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplain(t *testing.T) {
+	duration6h30m, _ := time.ParseDuration("6h30m")
+	duration21h30m, _ := time.ParseDuration("21h30m")
+	book := New(NewMemoryStore(), "123456", "999999", duration6h30m, duration21h30m)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:     []CodeType{"active", "count", "day"},
+		Name:      "DPD",
+		Digits:    "482913",
+		MaxUsage:  1,
+		Days:      []Day{Mon, Tue, Wed, Thu, Fri},
+		StartTime: "09:00",
+		EndTime:   "17:00",
+	}
+	book.store.Put(&code)
+
+	// Saturday
+	at, _ := time.Parse(ISO8601, "2017-11-11T08:15:00+0000")
+	e, err := book.Explain("482913", at)
+	if err != nil {
+		t.Errorf("Expected no error, got %v\n", err)
+	}
+	if e.Valid || e.Reason != OutsideDay || e.Name != "DPD" {
+		t.Errorf("Expected code to be denied outside its window, got %v\n", e)
+	}
+	if len(e.Rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %v\n", e.Rules)
+	}
+	if !e.Rules[0].Passed || !e.Rules[1].Passed || e.Rules[2].Passed {
+		t.Errorf("Expected only the day rule to fail, got %v\n", e.Rules)
+	}
+	if !strings.Contains(e.String(), "FAIL day: Mon,Tue,Wed,Thu,Fri 09:00 -> 17:00") {
+		t.Errorf("Expected failed day rule to be explained, got %v\n", e)
+	}
+
+	// Monday
+	at, _ = time.Parse(ISO8601, "2017-11-13T10:00:00+0000")
+	e, _ = book.Explain("482913", at)
+	if !e.Valid || e.Reason != Accepted {
+		t.Errorf("Expected code to be accepted, got %v\n", e)
+	}
+	actual, _ := book.Get("482913")
+	if actual.Usage != 0 || actual.Version != 1 {
+		t.Errorf("Expected explaining to leave the code unchanged, got %v\n", actual)
+	}
+	if !book.Check("482913", at).Valid {
+		t.Errorf("Expected check to succeed after explaining\n")
+	}
+}
+
+func TestExplainUnknownAndProposed(t *testing.T) {
+	book := New(NewMemoryStore(), "123456", "999999", 0, 0)
+	defer book.Close()
+
+	at, _ := time.Parse(ISO8601, "2017-11-11T08:15:00+0000")
+	e, _ := book.Explain("5555", at)
+	if e.Valid || e.Reason != NotFound {
+		t.Errorf("Expected unknown code, got %v\n", e)
+	}
+	e, _ = book.Explain("123456", at)
+	if !e.Valid {
+		t.Errorf("Expected admin code to be accepted, got %v\n", e)
+	}
+
+	proposed := &AccessCode{
+		Types:      []CodeType{"active", "interval"},
+		Name:       "Cleaner",
+		Digits:     "6789",
+		ValidFrom:  "2017-11-12T00:00:00+0000",
+		Expiration: "2017-11-13T00:00:00+0000",
+	}
+	e = book.ExplainCode(proposed, at)
+	if e.Valid || e.Reason != OutsideInterval {
+		t.Errorf("Expected proposed code to be outside its interval, got %v\n", e)
+	}
+	if code, _ := book.Get("6789"); code != nil {
+		t.Errorf("Expected proposed code not to be stored\n")
+	}
+}
@@ -1,9 +1,14 @@
 package codebook
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -11,62 +16,124 @@ import (
 
 const (
 	bucketName = "access_codes"
+	secretSize = 32
 )
 
-var db *bolt.DB
-var open bool
+// BoltStore keeps the codebook in a bolt database, with codes stored under a
+// keyed hash of their digits
+type BoltStore struct {
+	db     *bolt.DB
+	secret []byte
+}
 
-func OpenStore(codebookPath string) error {
+// OpenBoltStore opens, and if need be migrates, the codebook at the path
+func OpenBoltStore(codebookPath string) (*BoltStore, error) {
 	var err error
 	dbfile := "codebook.db"
 	if codebookPath != "" {
 		dbfile = codebookPath
 	}
+	s := &BoltStore{}
+	s.secret, err = loadSecret(dbfile + ".key")
+	if err != nil {
+		return nil, err
+	}
 	config := &bolt.Options{Timeout: 1 * time.Second}
-	db, err = bolt.Open(dbfile, 0600, config)
+	s.db, err = bolt.Open(dbfile, 0600, config)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	err2 := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(bucketName))
-		if err != nil {
-			return fmt.Errorf("CODEBOOK: create bucket: %s", err)
-		}
-		return err
-	})
-	if err2 != nil {
-		log.Fatal(err2)
+	err = s.migrate(dbfile)
+	if err != nil {
+		s.db.Close()
+		return nil, err
 	}
-	open = true
-	return nil
+	return s, nil
 }
 
-func CloseStore() {
-	open = false
-	db.Close()
+// OpenBoltStoreReadOnly opens the codebook at the path without changing it, or
+// its secret, on disk. The codebook must already be at the current schema
+// version.
+func OpenBoltStoreReadOnly(codebookPath string) (*BoltStore, error) {
+	var err error
+	dbfile := "codebook.db"
+	if codebookPath != "" {
+		dbfile = codebookPath
+	}
+	s := &BoltStore{}
+	s.secret, err = readSecret(dbfile + ".key")
+	if err != nil {
+		return nil, err
+	}
+	config := &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true}
+	s.db, err = bolt.Open(dbfile, 0600, config)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkSchema()
+	if err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-func (p *AccessCode) save() error {
-	if !open {
-		return fmt.Errorf("CODEBOOK: db must be opened before saving")
-	}
-	err := db.Update(func(tx *bolt.Tx) error {
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStore) Put(p *AccessCode) error {
+	_, err := s.Modify(p.Digits, func(*AccessCode) (*AccessCode, error) {
+		return p, nil
+	})
+	return err
+}
+
+func (s *BoltStore) Modify(digits string, fn func(code *AccessCode) (*AccessCode, error)) (*AccessCode, error) {
+	var result *AccessCode
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		codes, err := tx.CreateBucketIfNotExists([]byte(bucketName))
 		if err != nil {
 			return fmt.Errorf("CODEBOOK: create bucket: %s", err)
 		}
-		enc, err := p.encode()
+		k := s.codeKey(digits)
+		var current *AccessCode
+		if record := codes.Get(k); len(record) > 0 {
+			current, err = decode(record)
+			if err != nil {
+				return err
+			}
+			current.Digits = digits
+		}
+		result = current
+		updated, err := fn(current)
+		if err != nil || updated == nil {
+			return err
+		}
+		stored := *updated
+		stored.Digits = digits
+		stored.Version = 1
+		if current != nil {
+			stored.Version = current.Version + 1
+		}
+		enc, err := stored.encode()
 		if err != nil {
-			return fmt.Errorf("CODEBOOK: could not encode AccessCode %s: %s", p.Digits, err)
+			return fmt.Errorf("CODEBOOK: could not encode AccessCode %s: %s", stored.Name, err)
 		}
-		err = codes.Put([]byte(p.Digits), enc)
-		return err
+		result = &stored
+		return codes.Put(k, enc)
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
+// encode never includes the digits; a record is only found by hashing them
 func (p *AccessCode) encode() ([]byte, error) {
-	enc, err := json.Marshal(p)
+	stored := *p
+	stored.Digits = ""
+	enc, err := json.Marshal(&stored)
 	if err != nil {
 		return nil, err
 	}
@@ -82,15 +149,12 @@ func decode(data []byte) (*AccessCode, error) {
 	return p, nil
 }
 
-func GetAccessCode(digits string) (*AccessCode, error) {
-	if !open {
-		return nil, fmt.Errorf("CODEBOOK: db must be opened before saving")
-	}
+func (s *BoltStore) Get(digits string) (*AccessCode, error) {
 	var p *AccessCode
-	err := db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		var err error
 		b := tx.Bucket([]byte(bucketName))
-		k := []byte(digits)
+		k := s.codeKey(digits)
 		record := b.Get(k)
 		if len(record) <= 0 {
 			return nil
@@ -99,6 +163,7 @@ func GetAccessCode(digits string) (*AccessCode, error) {
 		if err != nil {
 			return err
 		}
+		p.Digits = digits
 		return nil
 	})
 	if err != nil {
@@ -108,12 +173,182 @@ func GetAccessCode(digits string) (*AccessCode, error) {
 	return p, nil
 }
 
-func List() {
-	db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket([]byte(bucketName)).Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			fmt.Printf("CODEBOOK: key=%s, value=%s\n", k, v)
+// List returns every stored code; as only their hashes are kept, the codes
+// have no digits
+func (s *BoltStore) List() ([]*AccessCode, error) {
+	codes := []*AccessCode{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).ForEach(func(k, v []byte) error {
+			p, err := decode(v)
+			if err != nil {
+				return err
+			}
+			codes = append(codes, p)
+			return nil
+		})
+	})
+	return codes, err
+}
+
+func (s *BoltStore) Record(entry AuditEntry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		audit, err := tx.CreateBucketIfNotExists([]byte(auditBucketName))
+		if err != nil {
+			return fmt.Errorf("CODEBOOK: create bucket: %s", err)
+		}
+		seq, err := audit.NextSequence()
+		if err != nil {
+			return err
+		}
+		enc, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("CODEBOOK: could not encode AuditEntry %v: %s", entry, err)
+		}
+		return audit.Put(auditKey(entry.Time, seq), enc)
+	})
+}
+
+func (s *BoltStore) QueryAudit(q AuditQuery) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		audit := tx.Bucket([]byte(auditBucketName))
+		if audit == nil {
+			return nil
+		}
+		c := audit.Cursor()
+		k, v := c.First()
+		if !q.From.IsZero() {
+			k, v = c.Seek(auditKey(q.From, 0))
+		}
+		for ; k != nil; k, v = c.Next() {
+			var entry AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if !q.To.IsZero() && !entry.Time.Before(q.To) {
+				break
+			}
+			if q.matches(&entry) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (s *BoltStore) PruneAudit(before time.Time) (int, error) {
+	cutoff := auditKey(before, 0)
+	pruned := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		audit := tx.Bucket([]byte(auditBucketName))
+		if audit == nil {
+			return nil
+		}
+		c := audit.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+func (s *BoltStore) GetState(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket([]byte(stateBucketName)).Get([]byte(key)); v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return value, err
+}
+
+func (s *BoltStore) PutState(key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(stateBucketName)).Put([]byte(key), value)
+	})
+}
+
+// codeKey is the keyed hash under which the code for the digits is stored
+func (s *BoltStore) codeKey(digits string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(digits))
+	return mac.Sum(nil)
+}
+
+// hashLegacyKeys is a schema migration that moves codes stored under their
+// plaintext digits to their hashed key, dropping the digits from the stored
+// record
+func hashLegacyKeys(s *BoltStore, tx *bolt.Tx) error {
+	codes := tx.Bucket([]byte(bucketName))
+	legacy := map[string][]byte{}
+	err := codes.ForEach(func(k, v []byte) error {
+		if len(k) != sha256.Size {
+			legacy[string(k)] = v
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	for digits, record := range legacy {
+		p, err := decode(record)
+		if err != nil {
+			return fmt.Errorf("CODEBOOK: could not decode legacy AccessCode: %s", err)
+		}
+		enc, err := p.encode()
+		if err != nil {
+			return err
+		}
+		if err = codes.Put(s.codeKey(digits), enc); err != nil {
+			return err
+		}
+		if err = codes.Delete([]byte(digits)); err != nil {
+			return err
+		}
+	}
+	if len(legacy) > 0 {
+		log.Printf("CODEBOOK: Hashed %v plaintext codes\n", len(legacy))
+	}
+	return nil
+}
+
+// loadSecret reads the device secret used to key code hashes, creating it on
+// first use. It is kept beside, not inside, the codebook so that a copy of the
+// database alone does not reveal any codes.
+func loadSecret(path string) ([]byte, error) {
+	key, err := readSecret(path)
+	if err == nil || !os.IsNotExist(err) {
+		return key, err
+	}
+	key = make([]byte, secretSize)
+	if _, err = rand.Read(key); err != nil {
+		return nil, fmt.Errorf("CODEBOOK: generate secret: %s", err)
+	}
+	if err = os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("CODEBOOK: write secret: %s", err)
+	}
+	log.Printf("CODEBOOK: Created secret: %v\n", path)
+	return key, nil
+}
+
+// readSecret reads an existing device secret, returning an error satisfying
+// os.IsNotExist if there is none
+func readSecret(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("CODEBOOK: read secret: %s", err)
+	}
+	if len(key) < secretSize {
+		return nil, fmt.Errorf("CODEBOOK: secret too short: %s", path)
+	}
+	return key, nil
 }
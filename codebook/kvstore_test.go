@@ -1,23 +1,27 @@
 package codebook
 
 import (
+	"bytes"
+	"path/filepath"
 	"testing"
+
+	"github.com/boltdb/bolt"
 )
 
 func TestOpenClose(t *testing.T) {
-	err := OpenStore("")
-	defer CloseStore()
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "codebook.db"))
 	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
+		t.Fatalf("Expected no error, got %v\n", err)
 	}
+	defer store.Close()
 }
 
 func TestSaveAndGet(t *testing.T) {
-	err := OpenStore("")
-	defer CloseStore()
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "codebook.db"))
 	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
+		t.Fatalf("Expected no error, got %v\n", err)
 	}
+	defer store.Close()
 
 	code := AccessCode{
 		Types:         []CodeType{"active", "duration", "interval", "count"},
@@ -30,9 +34,9 @@ func TestSaveAndGet(t *testing.T) {
 		MaxUsage:      3,
 		Usage:         1,
 	}
-	code.save()
+	store.Put(&code)
 
-	actual, err := GetAccessCode("123456")
+	actual, err := store.Get("123456")
 	if err != nil {
 		t.Errorf("Expected no error, got %v\n", err)
 	}
@@ -75,11 +79,11 @@ func TestSaveAndGet(t *testing.T) {
 }
 
 func TestSaveAndGetOther(t *testing.T) {
-	err := OpenStore("")
-	defer CloseStore()
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "codebook.db"))
 	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
+		t.Fatalf("Expected no error, got %v\n", err)
 	}
+	defer store.Close()
 
 	code := AccessCode{
 		Types:         []CodeType{"active", "duration", "interval", "count"},
@@ -92,9 +96,9 @@ func TestSaveAndGetOther(t *testing.T) {
 		MaxUsage:      3,
 		Usage:         1,
 	}
-	code.save()
+	store.Put(&code)
 
-	actual, err := GetAccessCode("4444")
+	actual, err := store.Get("4444")
 	if err != nil {
 		t.Errorf("Expected no error, got %v\n", err)
 	}
@@ -104,13 +108,13 @@ func TestSaveAndGetOther(t *testing.T) {
 }
 
 func TestGetOther(t *testing.T) {
-	err := OpenStore("")
-	defer CloseStore()
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "codebook.db"))
 	if err != nil {
-		t.Errorf("Expected no error, got %v\n", err)
+		t.Fatalf("Expected no error, got %v\n", err)
 	}
+	defer store.Close()
 
-	actual, err := GetAccessCode("4444")
+	actual, err := store.Get("4444")
 	if err != nil {
 		t.Errorf("Expected no error, got %v\n", err)
 	}
@@ -118,3 +122,63 @@ func TestGetOther(t *testing.T) {
 		t.Errorf("Expected nil\n")
 	}
 }
+
+func TestDigitsNotStored(t *testing.T) {
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "codebook.db"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	defer store.Close()
+
+	code := AccessCode{
+		Types:  []CodeType{"active"},
+		Name:   "Test",
+		Digits: "918273",
+	}
+	store.Put(&code)
+
+	store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).ForEach(func(k, v []byte) error {
+			if bytes.Contains(k, []byte("918273")) || bytes.Contains(v, []byte("918273")) {
+				t.Errorf("Expected digits to be hashed, got key=%x, value=%s\n", k, v)
+			}
+			return nil
+		})
+	})
+
+	actual, _ := store.Get("918273")
+	if actual == nil || actual.Digits != "918273" {
+		t.Errorf("Expected code to be found by digits, got %v\n", actual)
+	}
+}
+
+func TestMigrateLegacyKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codebook.db")
+	legacy, _ := bolt.Open(path, 0600, nil)
+	legacy.Update(func(tx *bolt.Tx) error {
+		codes, _ := tx.CreateBucketIfNotExists([]byte(bucketName))
+		return codes.Put([]byte("6789"), []byte(`{"Digits":"6789","Name":"Legacy","Types":["active"]}`))
+	})
+	legacy.Close()
+
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	defer store.Close()
+
+	actual, _ := store.Get("6789")
+	if actual == nil || actual.Name != "Legacy" {
+		t.Fatalf("Expected migrated code, got %v\n", actual)
+	}
+	store.db.View(func(tx *bolt.Tx) error {
+		codes := tx.Bucket([]byte(bucketName))
+		if codes.Get([]byte("6789")) != nil {
+			t.Errorf("Expected plaintext key to be removed\n")
+		}
+		if bytes.Contains(codes.Get(store.codeKey("6789")), []byte("6789")) {
+			t.Errorf("Expected digits to be removed from record\n")
+		}
+		return nil
+	})
+}
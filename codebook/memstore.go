@@ -0,0 +1,130 @@
+package codebook
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the codebook in memory, for tests and development
+type MemoryStore struct {
+	mu    sync.Mutex
+	codes map[string]AccessCode
+	audit []AuditEntry
+	state map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{codes: map[string]AccessCode{}, state: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Get(digits string) (*AccessCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.codes[digits]
+	if !ok {
+		return nil, nil
+	}
+	return code.clone(), nil
+}
+
+func (s *MemoryStore) Put(code *AccessCode) error {
+	_, err := s.Modify(code.Digits, func(*AccessCode) (*AccessCode, error) {
+		return code, nil
+	})
+	return err
+}
+
+func (s *MemoryStore) Modify(digits string, fn func(code *AccessCode) (*AccessCode, error)) (*AccessCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current *AccessCode
+	if code, ok := s.codes[digits]; ok {
+		current = code.clone()
+	}
+	updated, err := fn(current)
+	if err != nil || updated == nil {
+		return current, err
+	}
+	stored := *updated.clone()
+	stored.Digits = digits
+	stored.Version = 1
+	if current != nil {
+		stored.Version = current.Version + 1
+	}
+	s.codes[digits] = stored
+	return stored.clone(), nil
+}
+
+func (s *MemoryStore) List() ([]*AccessCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := []*AccessCode{}
+	for _, code := range s.codes {
+		codes = append(codes, code.clone())
+	}
+	return codes, nil
+}
+
+func (s *MemoryStore) Record(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.audit), func(i int) bool {
+		return s.audit[i].Time.After(entry.Time)
+	})
+	s.audit = append(s.audit, AuditEntry{})
+	copy(s.audit[i+1:], s.audit[i:])
+	s.audit[i] = entry
+	return nil
+}
+
+func (s *MemoryStore) QueryAudit(q AuditQuery) ([]AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []AuditEntry{}
+	for i := range s.audit {
+		if q.matches(&s.audit[i]) {
+			entries = append(entries, s.audit[i])
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) PruneAudit(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.audit), func(i int) bool {
+		return !s.audit[i].Time.Before(before)
+	})
+	s.audit = s.audit[i:]
+	return i, nil
+}
+
+func (s *MemoryStore) GetState(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.state[key]
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (s *MemoryStore) PutState(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// clone copies the code so that stored slices are not shared with callers
+func (c *AccessCode) clone() *AccessCode {
+	copied := *c
+	copied.Types = append([]CodeType(nil), c.Types...)
+	copied.Days = append([]Day(nil), c.Days...)
+	return &copied
+}
@@ -0,0 +1,75 @@
+package codebook
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Reservation is a use of a code held between the code being accepted and the
+// door being opened. A nil Reservation may be committed or released freely.
+type Reservation struct {
+	book    *Codebook
+	digits  string
+	at      time.Time
+	settled bool
+}
+
+// Commit counts the reserved use against the code, stamping its first use
+// with the time it was accepted. The code's rules are applied again as the use
+// is counted, so that an update or rescind since it was accepted is honoured.
+func (r *Reservation) Commit() error {
+	if r == nil {
+		return nil
+	}
+	cb := r.book
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if r.settled {
+		return nil
+	}
+	r.settled = true
+	cb.unreserve(r.digits)
+
+	_, err := cb.store.Modify(r.digits, func(code *AccessCode) (*AccessCode, error) {
+		if code == nil {
+			return nil, fmt.Errorf("code removed before use was committed")
+		}
+		// Other reservations may yet be released, so only committed uses count
+		if reason := cb.evaluate(code, r.at, 0); reason != Accepted {
+			return nil, fmt.Errorf("code no longer accepted when use was committed: %v", reason)
+		}
+		if code.Usage == 0 {
+			code.FirstUse = IsoTimestamp(r.at.Format(ISO8601))
+		}
+		code.Usage++
+		return code, nil
+	})
+	if err != nil {
+		log.Printf("CODEBOOK: Error committing code use: %v, %v\n", r.digits, err)
+	}
+	return err
+}
+
+// Release returns the reserved use without counting it
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	cb := r.book
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if r.settled {
+		return
+	}
+	r.settled = true
+	cb.unreserve(r.digits)
+	log.Printf("CODEBOOK: Released unused code: %v\n", r.digits)
+}
+
+func (cb *Codebook) unreserve(digits string) {
+	cb.reserved[digits]--
+	if cb.reserved[digits] <= 0 {
+		delete(cb.reserved, digits)
+	}
+}
@@ -0,0 +1,114 @@
+package codebook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReservationRelease(t *testing.T) {
+	book := New(NewMemoryStore(), "123456", "999999", 0, 0)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:    []CodeType{"active", "count"},
+		Name:     "Courier",
+		Digits:   "6789",
+		MaxUsage: 1,
+	}
+	book.store.Put(&code)
+
+	result := book.Check("6789", time.Now().UTC())
+	if !result.Valid {
+		t.Errorf("Expected check to succeed\n")
+	}
+	result.Valid = book.Check("6789", time.Now().UTC()).Valid
+	if result.Valid {
+		t.Errorf("Expected check to fail while the only use is reserved\n")
+	}
+
+	result.Reservation.Release()
+	actual, _ := book.Get("6789")
+	if actual.Usage != 0 || actual.FirstUse != "" {
+		t.Errorf("Expected released code to be unused, got %v\n", actual)
+	}
+
+	result = book.Check("6789", time.Now().UTC())
+	if !result.Valid {
+		t.Errorf("Expected check to succeed after release\n")
+	}
+	result.Reservation.Commit()
+	result.Reservation.Commit()
+	result.Reservation.Release()
+	actual, _ = book.Get("6789")
+	if actual.Usage != 1 {
+		t.Errorf("Expected a single committed use, got %v\n", actual.Usage)
+	}
+	result.Valid = book.Check("6789", time.Now().UTC()).Valid
+	if result.Valid {
+		t.Errorf("Expected check to fail once used\n")
+	}
+}
+
+func TestReservationMaster(t *testing.T) {
+	book := New(NewMemoryStore(), "123456", "999999", 0, 0)
+	defer book.Close()
+
+	result := book.Check("123456", time.Now().UTC())
+	if !result.Valid {
+		t.Errorf("Expected check to succeed\n")
+	}
+	if result.Reservation != nil {
+		t.Errorf("Expected no reservation for the admin code\n")
+	}
+	if err := result.Reservation.Commit(); err != nil {
+		t.Errorf("Expected no error, got %v\n", err)
+	}
+	result.Reservation.Release()
+}
+
+func TestReservationChangedBeforeCommit(t *testing.T) {
+	book := New(NewMemoryStore(), "123456", "999999", 0, 0)
+	defer book.Close()
+
+	code := AccessCode{
+		Types:    []CodeType{"active", "count"},
+		Name:     "Courier",
+		Digits:   "6789",
+		MaxUsage: 2,
+	}
+	book.store.Put(&code)
+
+	now := time.Now().UTC()
+	result := book.Check("6789", now)
+	if !result.Valid {
+		t.Errorf("Expected check to succeed\n")
+	}
+	book.Rescind(&code.Digits)
+	if err := result.Reservation.Commit(); err == nil {
+		t.Errorf("Expected commit of a rescinded code to fail\n")
+	}
+	actual, _ := book.Get("6789")
+	if actual.Usage != 0 {
+		t.Errorf("Expected rescinded code to be unused, got %v\n", actual.Usage)
+	}
+
+	book.store.Put(&code)
+	result = book.Check("6789", now)
+	other := book.Check("6789", now)
+	if !result.Valid || !other.Valid {
+		t.Errorf("Expected both checks to succeed\n")
+	}
+	lowered := code
+	lowered.MaxUsage = 1
+	book.Update(&lowered)
+	if err := result.Reservation.Commit(); err != nil {
+		t.Errorf("Expected the first use to be committed, got %v\n", err)
+	}
+	if err := other.Reservation.Commit(); err == nil {
+		t.Errorf("Expected commit beyond the updated MaxUsage to fail\n")
+	}
+	actual, _ = book.Get("6789")
+	if actual.Usage != 1 {
+		t.Errorf("Expected a single committed use, got %v\n", actual.Usage)
+	}
+}
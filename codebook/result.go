@@ -0,0 +1,92 @@
+package codebook
+
+import "time"
+
+// Reason explains the decision made by Check
+type Reason string
+
+// Notify is how an accepted code should be notified
+type Notify string
+
+// Result is the decision made by Check, with the identity of the code and how
+// its use should be notified
+type Result struct {
+	Valid  bool
+	Reason Reason
+	// Name of the code, empty if the digits matched no code
+	Name   string
+	Master bool
+	Notify Notify
+	// Alarm is raised for a duress code, without any sign of it at the door
+	Alarm bool
+	// Expiring is set on an accepted code that is being used for the last
+	// time, or that expires within a day
+	Expiring bool
+	// Reservation holds the use of an accepted code, see Check
+	Reservation *Reservation
+}
+
+const (
+	Accepted        Reason = "accepted"
+	NotFound        Reason = "not_found"
+	Rescinded       Reason = "rescinded"
+	UsageExceeded   Reason = "usage_exceeded"
+	DurationExpired Reason = "duration_expired"
+	OutsideInterval Reason = "outside_interval"
+	OutsideDay      Reason = "outside_day"
+	Misconfigured   Reason = "misconfigured"
+
+	NotifyNormal Notify = "normal"
+	NotifySilent Notify = "silent"
+)
+
+var reasonDescriptions = map[Reason]string{
+	Accepted:        "accepted",
+	NotFound:        "not recognised",
+	Rescinded:       "rescinded",
+	UsageExceeded:   "used more than allowed",
+	DurationExpired: "used after it expired",
+	OutsideInterval: "used outside its valid dates",
+	OutsideDay:      "used outside its window",
+	Misconfigured:   "misconfigured",
+}
+
+// Describe phrases the reason for a notification, e.g. "code for DPD used
+// outside its window"
+func (r Reason) Describe() string {
+	if description, ok := reasonDescriptions[r]; ok {
+		return description
+	}
+	return string(r)
+}
+
+const expiryWarning = 24 * time.Hour
+
+// expiring reports whether an accepted code, with the uses already reserved,
+// is on its last use or close to expiry
+func (c *AccessCode) expiring(now time.Time, reserved int) bool {
+	if c.hasType(Count) && c.Usage+reserved+1 >= c.MaxUsage {
+		return true
+	}
+	if c.hasType(Interval) {
+		if to, err := time.Parse(ISO8601, string(c.Expiration)); err == nil && to.Sub(now) < expiryWarning {
+			return true
+		}
+	}
+	if c.hasType(Duration) && c.Usage > 0 {
+		if firstUse, err := time.Parse(ISO8601, string(c.FirstUse)); err == nil {
+			expiry := firstUse.Add(time.Duration(c.ValidityHours) * time.Hour)
+			if expiry.Sub(now) < expiryWarning {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *AccessCode) notify() Notify {
+	if c.hasType(Silent) {
+		return NotifySilent
+	}
+	return NotifyNormal
+}
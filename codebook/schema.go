@@ -0,0 +1,134 @@
+package codebook
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	metaBucketName   = "meta"
+	schemaVersionKey = "schema_version"
+	stateBucketName  = "state"
+)
+
+type migration struct {
+	description string
+	apply       func(s *BoltStore, tx *bolt.Tx) error
+}
+
+// migrations are applied in order, the schema version of a codebook being the
+// number of migrations it has had applied. Steps are only ever appended.
+var migrations = []migration{
+	{"create access code and audit buckets", createBuckets},
+	{"hash access code keys", hashLegacyKeys},
+	{"create state bucket", createStateBucket},
+}
+
+// SchemaVersion is the codebook schema version understood by this binary
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// migrate brings the open codebook up to the current schema version, taking a
+// backup first if it already holds data
+func (s *BoltStore) migrate(dbfile string) error {
+	var version int
+	var populated bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = readSchemaVersion(tx)
+		populated = tx.Bucket([]byte(bucketName)) != nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion() {
+		return fmt.Errorf("CODEBOOK: schema version %v is newer than supported version %v", version, SchemaVersion())
+	}
+	if version == SchemaVersion() {
+		return nil
+	}
+
+	if populated {
+		backup := fmt.Sprintf("%s.v%d.bak", dbfile, version)
+		err = s.db.View(func(tx *bolt.Tx) error {
+			return tx.CopyFile(backup, 0600)
+		})
+		if err != nil {
+			return fmt.Errorf("CODEBOOK: backup before migration: %s", err)
+		}
+		log.Printf("CODEBOOK: Backed up schema version %v to %v\n", version, backup)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for v := version; v < SchemaVersion(); v++ {
+			log.Printf("CODEBOOK: Migrating to schema version %v: %v\n", v+1, migrations[v].description)
+			err := migrations[v].apply(s, tx)
+			if err != nil {
+				return fmt.Errorf("CODEBOOK: migration to schema version %v: %s", v+1, err)
+			}
+		}
+		return writeSchemaVersion(tx, SchemaVersion())
+	})
+}
+
+// checkSchema refuses a codebook that is not at the current schema version,
+// for when it is opened read only and cannot be migrated
+func (s *BoltStore) checkSchema() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		version, err := readSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if version != SchemaVersion() {
+			return fmt.Errorf("CODEBOOK: schema version %v is not supported version %v", version, SchemaVersion())
+		}
+		return nil
+	})
+}
+
+func readSchemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket([]byte(metaBucketName))
+	if meta == nil {
+		return 0, nil
+	}
+	value := meta.Get([]byte(schemaVersionKey))
+	if value == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("CODEBOOK: invalid schema version: %q", value)
+	}
+	return version, nil
+}
+
+func writeSchemaVersion(tx *bolt.Tx, version int) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+	if err != nil {
+		return fmt.Errorf("CODEBOOK: create bucket: %s", err)
+	}
+	return meta.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+func createBuckets(s *BoltStore, tx *bolt.Tx) error {
+	for _, name := range []string{bucketName, auditBucketName, metaBucketName} {
+		_, err := tx.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return fmt.Errorf("CODEBOOK: create bucket: %s", err)
+		}
+	}
+	return nil
+}
+
+func createStateBucket(s *BoltStore, tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists([]byte(stateBucketName))
+	if err != nil {
+		return fmt.Errorf("CODEBOOK: create bucket: %s", err)
+	}
+	return nil
+}
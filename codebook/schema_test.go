@@ -0,0 +1,120 @@
+package codebook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestSchemaVersionNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codebook.db")
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	defer store.Close()
+
+	store.db.View(func(tx *bolt.Tx) error {
+		version, _ := readSchemaVersion(tx)
+		if version != SchemaVersion() {
+			t.Errorf("Expected schema version %v, got %v\n", SchemaVersion(), version)
+		}
+		return nil
+	})
+	if _, err := os.Stat(path + ".v0.bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup of an empty codebook\n")
+	}
+}
+
+func TestSchemaBackupBeforeMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codebook.db")
+	legacy, _ := bolt.Open(path, 0600, nil)
+	legacy.Update(func(tx *bolt.Tx) error {
+		codes, _ := tx.CreateBucketIfNotExists([]byte(bucketName))
+		return codes.Put([]byte("6789"), []byte(`{"Digits":"6789","Name":"Legacy","Types":["active"]}`))
+	})
+	legacy.Close()
+
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	defer store.Close()
+
+	backup, err := bolt.Open(path+".v0.bak", 0600, nil)
+	if err != nil {
+		t.Fatalf("Expected backup, got %v\n", err)
+	}
+	defer backup.Close()
+	backup.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(bucketName)).Get([]byte("6789")) == nil {
+			t.Errorf("Expected backup to hold the unmigrated code\n")
+		}
+		return nil
+	})
+}
+
+func TestSchemaNewerRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codebook.db")
+	newer, _ := bolt.Open(path, 0600, nil)
+	newer.Update(func(tx *bolt.Tx) error {
+		return writeSchemaVersion(tx, SchemaVersion()+1)
+	})
+	newer.Close()
+
+	store, err := OpenBoltStore(path)
+	if err == nil {
+		store.Close()
+		t.Errorf("Expected newer schema to be refused\n")
+	}
+}
+
+func TestReadOnlyRefusesOldSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codebook.db")
+	legacy, _ := bolt.Open(path, 0600, nil)
+	legacy.Update(func(tx *bolt.Tx) error {
+		codes, _ := tx.CreateBucketIfNotExists([]byte(bucketName))
+		return codes.Put([]byte("6789"), []byte(`{"Digits":"6789","Name":"Legacy","Types":["active"]}`))
+	})
+	legacy.Close()
+	os.WriteFile(path+".key", make([]byte, secretSize), 0600)
+
+	store, err := OpenBoltStoreReadOnly(path)
+	if err == nil {
+		store.Close()
+		t.Errorf("Expected old schema to be refused\n")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected nothing written beside the codebook, got %v\n", len(entries))
+	}
+}
+
+func TestReadOnlyNeedsSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codebook.db")
+	store, _ := OpenBoltStore(path)
+	store.Put(&AccessCode{Digits: "6789", Name: "Test", Types: []CodeType{"active"}})
+	store.Close()
+
+	readOnly, err := OpenBoltStoreReadOnly(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v\n", err)
+	}
+	if code, _ := readOnly.Get("6789"); code == nil || code.Name != "Test" {
+		t.Errorf("Expected the stored code, got %v\n", code)
+	}
+	readOnly.Close()
+
+	os.Remove(path + ".key")
+	readOnly, err = OpenBoltStoreReadOnly(path)
+	if err == nil {
+		readOnly.Close()
+		t.Errorf("Expected a missing secret to be refused\n")
+	}
+	if _, err := os.Stat(path + ".key"); !os.IsNotExist(err) {
+		t.Errorf("Expected no secret to be created\n")
+	}
+}
@@ -0,0 +1,29 @@
+package codebook
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// LoadState decodes the value saved under the key into v, returning false if
+// nothing has been saved
+func (cb *Codebook) LoadState(key string, v interface{}) (bool, error) {
+	value, err := cb.store.GetState(key)
+	if err != nil || value == nil {
+		return false, err
+	}
+	if err = json.Unmarshal(value, v); err != nil {
+		return false, fmt.Errorf("CODEBOOK: could not decode state %s: %s", key, err)
+	}
+	return true, nil
+}
+
+// SaveState encodes and saves v under the key, so that subsystems can keep
+// state across restarts alongside the codes
+func (cb *Codebook) SaveState(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("CODEBOOK: could not encode state %s: %s", key, err)
+	}
+	return cb.store.PutState(key, value)
+}
@@ -0,0 +1,35 @@
+package codebook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestState(t *testing.T) {
+	type counter struct {
+		Count int
+		Since time.Time
+	}
+	for name, store := range testStores(t) {
+		book := New(store, "123456", "999999", 0, 0)
+		defer book.Close()
+
+		var loaded counter
+		found, err := book.LoadState("counter", &loaded)
+		if err != nil || found {
+			t.Errorf("%v: Expected no state, got %v, %v\n", name, found, err)
+		}
+
+		now, _ := time.Parse(ISO8601, "2017-11-09T11:10:03+0000")
+		if err = book.SaveState("counter", counter{Count: 3, Since: now}); err != nil {
+			t.Errorf("%v: Expected no error, got %v\n", name, err)
+		}
+		found, err = book.LoadState("counter", &loaded)
+		if err != nil || !found {
+			t.Errorf("%v: Expected state, got %v, %v\n", name, found, err)
+		}
+		if loaded.Count != 3 || !loaded.Since.Equal(now) {
+			t.Errorf("%v: Expected saved state, got %v\n", name, loaded)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package codebook
+
+import "time"
+
+// Store persists access codes and the audit log behind a Codebook. Every
+// write of a code increments its Version.
+type Store interface {
+	// Get returns the code for the digits, or nil if there is none
+	Get(digits string) (*AccessCode, error)
+	// Put creates or replaces the code for its digits
+	Put(code *AccessCode) error
+	// Modify reads and writes the code for the digits in one transaction.
+	// The function is given the current code, or nil if there is none, and
+	// returns the code to store, or nil to leave it unchanged. The stored, or
+	// unchanged, code is returned.
+	Modify(digits string, fn func(code *AccessCode) (*AccessCode, error)) (*AccessCode, error)
+	// List returns every stored code
+	List() ([]*AccessCode, error)
+
+	// Record appends an entry to the audit log
+	Record(entry AuditEntry) error
+	// QueryAudit returns the matching entries in time order
+	QueryAudit(q AuditQuery) ([]AuditEntry, error)
+	// PruneAudit deletes entries recorded before the given time
+	PruneAudit(before time.Time) (int, error)
+
+	// GetState returns the value saved under the key, or nil if there is none
+	GetState(key string) ([]byte, error)
+	// PutState saves a value under the key
+	PutState(key string, value []byte) error
+
+	Close() error
+}
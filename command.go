@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/teabot/parceldrop/codebook"
+)
+
+const usage = `Usage:
+  parceldrop                 run the door controller
+  parceldrop explain [-at time] digits
+  parceldrop explain [-at time] -code file.json
+`
+
+// runCommand runs a subcommand given on the command line, returning the exit
+// status
+func runCommand(args []string) int {
+	switch args[0] {
+	case "explain":
+		return explainCommand(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// explainCommand evaluates a stored code, or one held in a file, at a given
+// time without using it. The codebook is opened read only, and is neither
+// migrated nor given a secret; it is locked while the door controller is
+// running, in which case the SQS check instruction answers the same question.
+func explainCommand(args []string) int {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	at := flags.String("at", "", "time to evaluate at, as "+codebook.ISO8601+" (default now)")
+	codeFile := flags.String("code", "", "JSON file holding an AccessCode to evaluate instead of a stored code")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	when := time.Now()
+	if *at != "" {
+		var err error
+		when, err = time.Parse(codebook.ISO8601, *at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid time: %v\n", *at)
+			return 2
+		}
+	}
+	start, end := dayWindow()
+
+	var e codebook.Explanation
+	if *codeFile != "" {
+		data, err := os.ReadFile(*codeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read code: %v\n", err)
+			return 1
+		}
+		var code codebook.AccessCode
+		if err = json.Unmarshal(data, &code); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not decode code: %v\n", err)
+			return 1
+		}
+		e = codebook.New(codebook.NewMemoryStore(), os.Getenv("ADMIN_CODE"), defaultCode, start, end).ExplainCode(&code, when)
+	} else {
+		if flags.NArg() != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		store, err := codebook.OpenBoltStoreReadOnly(os.Getenv("CODEBOOK_PATH"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open codebook, use the SQS check instruction while parceldrop is running: %v\n", err)
+			return 1
+		}
+		explainer := codebook.New(store, os.Getenv("ADMIN_CODE"), defaultCode, start, end)
+		defer explainer.Close()
+		e, err = explainer.Explain(flags.Arg(0), when)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not explain code: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Println(e)
+	if !e.Valid {
+		return 1
+	}
+	return 0
+}
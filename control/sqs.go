@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/teabot/parceldrop/codebook"
+	"github.com/teabot/parceldrop/sms"
 )
 
 type InstructionType string
@@ -24,24 +25,46 @@ type Instruction struct {
 	InsType    InstructionType
 	Digits     *string
 	AccessCode *codebook.AccessCode
+	// At is the time to check a code at, defaulting to now
+	At *string
+	// setsUsage is true if the AccessCode has a Usage or FirstUse, even if
+	// zero, which an update takes in place of the stored usage
+	setsUsage bool
 }
 
 const (
 	OpenDoor    InstructionType = "open"
 	RescindCode InstructionType = "rescind"
 	UpdateCode  InstructionType = "update"
+	CheckCode   InstructionType = "check"
 )
 
+// Alarm is sent to the alarm queue, for Home Assistant or the like to act on
+type Alarm struct {
+	Type string
+	Name string
+	Time string
+}
+
 var openFn func(string)
+var book *codebook.Codebook
+var alarmSvc *sqs.SQS
+var alarmQueueURL string
+var onlineFn func(bool)
+var online = true
 
 // InitialiseSqs x
-func InitialiseSqs(queueURL string, overrideFn func(string)) {
+func InitialiseSqs(queueURL, alarmQueue string, codes *codebook.Codebook, overrideFn func(string), statusFn func(online bool)) {
 	openFn = overrideFn
+	onlineFn = statusFn
+	book = codes
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 	}))
 
 	svc := sqs.New(sess)
+	alarmSvc = svc
+	alarmQueueURL = alarmQueue
 	ticker := time.NewTicker(1 * time.Second)
 	go poll(ticker, svc, queueURL)
 }
@@ -73,8 +96,10 @@ func receive(svc *sqs.SQS, queueURL string) {
 
 	if err != nil {
 		log.Println("CONTROL: Error polling SQS", err)
+		setOnline(false)
 		return
 	}
+	setOnline(true)
 
 	if len(result.Messages) == 0 {
 		return
@@ -99,6 +124,19 @@ func receive(svc *sqs.SQS, queueURL string) {
 		}
 	}
 }
+
+// setOnline tells statusFn when SQS is lost or regained
+func setOnline(up bool) {
+	if up == online {
+		return
+	}
+	online = up
+	log.Printf("CONTROL: Online: %v\n", up)
+	if onlineFn != nil {
+		onlineFn(up)
+	}
+}
+
 func processPayload(payload *Instruction) {
 	switch payload.InsType {
 	case OpenDoor:
@@ -107,6 +145,8 @@ func processPayload(payload *Instruction) {
 		rescindInstruction(payload)
 	case UpdateCode:
 		updateInstruction(payload)
+	case CheckCode:
+		checkInstruction(payload)
 	default:
 		log.Printf("CONTROL: Unknown instruction type: %v\n", payload.InsType)
 	}
@@ -120,7 +160,7 @@ func openDoorInstruction(payload *Instruction) {
 func rescindInstruction(payload *Instruction) {
 	log.Println("CONTROL: Rescinding code")
 	if payload.Digits != nil {
-		err := codebook.Rescind(payload.Digits)
+		err := book.Rescind(payload.Digits)
 		if err != nil {
 			log.Printf("CONTROL: Error saving access code: %v, %v\n", payload.AccessCode, err)
 		}
@@ -130,19 +170,79 @@ func rescindInstruction(payload *Instruction) {
 func updateInstruction(payload *Instruction) {
 	log.Println("CONTROL: Updating code")
 	if payload.AccessCode != nil {
-		err := codebook.Update(payload.AccessCode)
+		var err error
+		if payload.setsUsage {
+			err = book.Replace(payload.AccessCode)
+		} else {
+			err = book.Update(payload.AccessCode)
+		}
 		if err != nil {
 			log.Printf("CONTROL: Error saving access code: %v, %v\n", payload.AccessCode, err)
 		}
 	}
 }
 
+// SendAlarm raises an alarm of the type, e.g. duress, on the alarm queue if
+// there is one. Nothing is logged, so that a duress alarm leaves no trace at
+// the door.
+func SendAlarm(alarmType, name string, at time.Time) {
+	if alarmSvc == nil || alarmQueueURL == "" {
+		return
+	}
+	body, err := json.Marshal(Alarm{Type: alarmType, Name: name, Time: at.Format(codebook.ISO8601)})
+	if err != nil {
+		return
+	}
+	go alarmSvc.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    aws.String(alarmQueueURL),
+		MessageBody: aws.String(string(body)),
+	})
+}
+
+// checkInstruction explains how a stored code, or a proposed one, would be
+// decided without using it
+func checkInstruction(payload *Instruction) {
+	log.Println("CONTROL: Checking code")
+	at := time.Now()
+	if payload.At != nil {
+		var err error
+		at, err = time.Parse(codebook.ISO8601, *payload.At)
+		if err != nil {
+			log.Printf("CONTROL: Invalid check time: %v, %v\n", *payload.At, err)
+			return
+		}
+	}
+	var explanation codebook.Explanation
+	if payload.AccessCode != nil {
+		explanation = book.ExplainCode(payload.AccessCode, at)
+	} else if payload.Digits != nil {
+		var err error
+		explanation, err = book.Explain(*payload.Digits, at)
+		if err != nil {
+			log.Printf("CONTROL: Error checking code: %v\n", err)
+			return
+		}
+	} else {
+		return
+	}
+	log.Printf("CONTROL: Check result: %v\n", explanation)
+	sms.SendCheckResult(explanation.String())
+}
+
 func decodeInstruction(data []byte) (*Instruction, error) {
 	var p *Instruction
 	err := json.Unmarshal(data, &p)
 	if err != nil {
 		return nil, err
 	}
+	var fields struct {
+		AccessCode map[string]json.RawMessage
+	}
+	if json.Unmarshal(data, &fields) == nil {
+		_, usage := fields.AccessCode["Usage"]
+		_, firstUse := fields.AccessCode["FirstUse"]
+		p.setsUsage = usage || firstUse
+	}
 	return p, nil
 }
 
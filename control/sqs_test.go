@@ -0,0 +1,22 @@
+package control
+
+import "testing"
+
+func TestDecodeUpdateUsage(t *testing.T) {
+	cases := map[string]bool{
+		`{"InsType": "update", "AccessCode": {"Digits": "6789", "Types": ["active"]}}`:                 false,
+		`{"InsType": "update", "AccessCode": {"Digits": "6789", "Types": ["active"], "Usage": 0}}`:     true,
+		`{"InsType": "update", "AccessCode": {"Digits": "6789", "Types": ["active"], "FirstUse": ""}}`: true,
+		`{"InsType": "rescind", "Digits": "6789"}`:                                                     false,
+	}
+	for data, expected := range cases {
+		p, err := decodeInstruction([]byte(data))
+		if err != nil {
+			t.Errorf("Expected no error for %v, got %v\n", data, err)
+			continue
+		}
+		if p.setsUsage != expected {
+			t.Errorf("Expected setsUsage %v for %v\n", expected, data)
+		}
+	}
+}
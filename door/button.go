@@ -0,0 +1,142 @@
+package door
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ButtonConfig sets what a press of the override button does, and how a
+// faulty button is kept from flooding unlocks
+type ButtonConfig struct {
+	// LongPress is how long the button is held to hold the door open
+	LongPress time.Duration
+	// HoldOpen is how long a long press keeps the door unlocked
+	HoldOpen time.Duration
+	// Cooldown is the least time between presses that unlock
+	Cooldown time.Duration
+	// MaxPresses within Window disable the button for Lockout
+	MaxPresses int
+	Window     time.Duration
+	Lockout    time.Duration
+}
+
+// DefaultButton holds the door open for ten minutes on a three second press
+var DefaultButton = ButtonConfig{
+	LongPress:  3 * time.Second,
+	HoldOpen:   10 * time.Minute,
+	Cooldown:   2 * time.Second,
+	MaxPresses: 10,
+	Window:     time.Minute,
+	Lockout:    10 * time.Minute,
+}
+
+// ButtonHandlers are called as the override button is used. Press is called
+// as soon as the button goes down, and Hold if it is then held for the long
+// press. Lockout is called once when the button is disabled.
+type ButtonHandlers struct {
+	Press   func()
+	Hold    func(d time.Duration)
+	Lockout func(until time.Time)
+}
+
+// Button gives the override input its press semantics
+type Button struct {
+	clock    Clock
+	config   ButtonConfig
+	handlers ButtonHandlers
+
+	mu          sync.Mutex
+	presses     []time.Time
+	lastPress   time.Time
+	lockedUntil time.Time
+	holdTimer   Timer
+	generation  int
+}
+
+// NewButton creates a button; call Watch to drive it from the input edges
+func NewButton(clock Clock, config ButtonConfig, handlers ButtonHandlers) *Button {
+	return &Button{clock: clock, config: config, handlers: handlers}
+}
+
+// Watch handles the override edges until the channel closes
+func (b *Button) Watch(edges <-chan Edge) {
+	for edge := range edges {
+		switch edge.Kind {
+		case OverridePressed:
+			b.Press(edge.At)
+		case OverrideReleased:
+			b.Release()
+		}
+	}
+}
+
+// Press handles the button going down
+func (b *Button) Press(now time.Time) {
+	b.mu.Lock()
+	if now.Before(b.lockedUntil) {
+		b.mu.Unlock()
+		log.Println("DOOR: Override button locked out, ignoring press")
+		return
+	}
+
+	recent := b.presses[:0]
+	for _, p := range b.presses {
+		if now.Sub(p) < b.config.Window {
+			recent = append(recent, p)
+		}
+	}
+	b.presses = append(recent, now)
+	if b.config.MaxPresses > 0 && len(b.presses) > b.config.MaxPresses {
+		b.lockedUntil = now.Add(b.config.Lockout)
+		b.presses = nil
+		b.stopHold()
+		until := b.lockedUntil
+		b.mu.Unlock()
+		log.Printf("DOOR: Override button locked out until %v\n", until)
+		b.handlers.Lockout(until)
+		return
+	}
+
+	if !b.lastPress.IsZero() && now.Sub(b.lastPress) < b.config.Cooldown {
+		b.mu.Unlock()
+		log.Println("DOOR: Override button cooling down, ignoring press")
+		return
+	}
+	b.lastPress = now
+	b.stopHold()
+	generation := b.generation
+	b.holdTimer = b.clock.AfterFunc(b.config.LongPress, func() { b.longPress(generation) })
+	b.mu.Unlock()
+
+	log.Println("DOOR: Override button pressed")
+	b.handlers.Press()
+}
+
+// Release handles the button going up, before or after a long press
+func (b *Button) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopHold()
+}
+
+func (b *Button) longPress(generation int) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	b.holdTimer = nil
+	b.mu.Unlock()
+
+	log.Println("DOOR: Override button held")
+	b.handlers.Hold(b.config.HoldOpen)
+}
+
+func (b *Button) stopHold() {
+	if b.holdTimer != nil {
+		b.holdTimer.Stop()
+		b.holdTimer = nil
+	}
+	b.generation++
+}
@@ -0,0 +1,108 @@
+package door
+
+import (
+	"testing"
+	"time"
+)
+
+type buttonCalls struct {
+	presses  int
+	holds    []time.Duration
+	lockouts int
+}
+
+var buttonConfig = ButtonConfig{
+	LongPress:  3 * time.Second,
+	HoldOpen:   10 * time.Minute,
+	Cooldown:   2 * time.Second,
+	MaxPresses: 3,
+	Window:     time.Minute,
+	Lockout:    10 * time.Minute,
+}
+
+func TestButtonShortPress(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	calls := &buttonCalls{}
+	b := NewButton(clock, buttonConfig, ButtonHandlers{
+		Press:   func() { calls.presses++ },
+		Hold:    func(d time.Duration) { calls.holds = append(calls.holds, d) },
+		Lockout: func(time.Time) { calls.lockouts++ },
+	})
+
+	b.Press(clock.Now())
+	clock.Advance(time.Second)
+	b.Release()
+	clock.Advance(5 * time.Second)
+	if calls.presses != 1 || len(calls.holds) != 0 {
+		t.Errorf("Expected a press and no hold, got %v\n", calls)
+	}
+}
+
+func TestButtonLongPress(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	calls := &buttonCalls{}
+	b := NewButton(clock, buttonConfig, ButtonHandlers{
+		Press:   func() { calls.presses++ },
+		Hold:    func(d time.Duration) { calls.holds = append(calls.holds, d) },
+		Lockout: func(time.Time) { calls.lockouts++ },
+	})
+
+	b.Press(clock.Now())
+	clock.Advance(3 * time.Second)
+	if calls.presses != 1 || len(calls.holds) != 1 || calls.holds[0] != 10*time.Minute {
+		t.Errorf("Expected a press then a hold, got %v\n", calls)
+	}
+	clock.Advance(time.Minute)
+	b.Release()
+	if len(calls.holds) != 1 {
+		t.Errorf("Expected a single hold, got %v\n", calls)
+	}
+}
+
+func TestButtonCooldown(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	calls := &buttonCalls{}
+	b := NewButton(clock, buttonConfig, ButtonHandlers{
+		Press:   func() { calls.presses++ },
+		Hold:    func(d time.Duration) { calls.holds = append(calls.holds, d) },
+		Lockout: func(time.Time) { calls.lockouts++ },
+	})
+
+	b.Press(clock.Now())
+	b.Release()
+	clock.Advance(time.Second)
+	b.Press(clock.Now())
+	b.Release()
+	if calls.presses != 1 {
+		t.Errorf("Expected press during cooldown to be ignored, got %v\n", calls.presses)
+	}
+	clock.Advance(time.Second)
+	b.Press(clock.Now())
+	if calls.presses != 2 {
+		t.Errorf("Expected press after cooldown, got %v\n", calls.presses)
+	}
+}
+
+func TestButtonLockout(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	calls := &buttonCalls{}
+	b := NewButton(clock, buttonConfig, ButtonHandlers{
+		Press:   func() { calls.presses++ },
+		Hold:    func(d time.Duration) { calls.holds = append(calls.holds, d) },
+		Lockout: func(time.Time) { calls.lockouts++ },
+	})
+
+	for i := 0; i < 6; i++ {
+		b.Press(clock.Now())
+		b.Release()
+		clock.Advance(5 * time.Second)
+	}
+	if calls.presses != 3 || calls.lockouts != 1 {
+		t.Errorf("Expected three presses then a single lockout, got %v\n", calls)
+	}
+	clock.Advance(10 * time.Minute)
+	b.Press(clock.Now())
+	if calls.presses != 4 {
+		t.Errorf("Expected button to work after lockout, got %v\n", calls.presses)
+	}
+}
@@ -0,0 +1,361 @@
+package door
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Phase of the door controller
+type Phase string
+
+// Event drives the door controller from one phase to another
+type Event string
+
+const (
+	Idle      Phase = "idle"
+	Verifying Phase = "verifying"
+	Unlocked  Phase = "unlocked"
+	Opened    Phase = "open"
+	HeldOpen  Phase = "held_open"
+	Rejected  Phase = "rejected"
+	Fault     Phase = "fault"
+
+	CodeEntered    Event = "code_entered"
+	CodeGranted    Event = "code_granted"
+	CodeDenied     Event = "code_denied"
+	CodeCancelled  Event = "code_cancelled"
+	OverrideUsed   Event = "override"
+	OverrideHeld   Event = "override_held"
+	HoldExpired    Event = "hold_expired"
+	ContactOpened  Event = "contact_opened"
+	ContactClosed  Event = "contact_closed"
+	TimedOut       Event = "timed_out"
+	SensorFault    Event = "sensor_fault"
+	SensorRestored Event = "sensor_restored"
+)
+
+// Hardware is what the controller drives
+type Hardware interface {
+	Unlock()
+	Lock()
+	Reject()
+	Wait()
+	Contact() (ContactState, error)
+}
+
+// Board is the Hardware of the initialised door
+type Board struct{}
+
+func (Board) Unlock()                        { Unlock() }
+func (Board) Lock()                          { Lock() }
+func (Board) Reject()                        { Reject() }
+func (Board) Wait()                          { Wait() }
+func (Board) Contact() (ContactState, error) { return ReadState() }
+
+// Clock provides the time and timers, so that tests can control both
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call made by a Clock
+type Timer interface {
+	Stop() bool
+}
+
+// SystemClock is the real time
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+func (SystemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// Timings are how long the controller waits in each phase
+type Timings struct {
+	// OpenWait is how long the door stays unlocked waiting to be opened
+	OpenWait time.Duration
+	// HeldOpen is how long the door may be open before it is held open
+	HeldOpen time.Duration
+	// Reject is how long a rejection is shown
+	Reject time.Duration
+	// Verify is how long a code may be entered or verified before it is
+	// abandoned, e.g. when the keypad is cleared
+	Verify time.Duration
+}
+
+// Transition is a change of phase, passed to observers
+type Transition struct {
+	From   Phase
+	To     Phase
+	Event  Event
+	At     time.Time
+	Detail string
+}
+
+// Observer is called on the controller goroutine for every transition, and
+// must not send events to the controller
+type Observer func(t Transition)
+
+// Controller runs the door through its phases on a single goroutine, driven by
+// events from the keypad, the contact sensor, overrides and its own timers
+type Controller struct {
+	hw      Hardware
+	clock   Clock
+	timings Timings
+	events  chan request
+
+	mu        sync.Mutex
+	phase     Phase
+	observers []Observer
+
+	timer      Timer
+	generation int
+	// holdUntil keeps the door unlocked, relatching after each close, until
+	// it passes
+	holdUntil time.Time
+}
+
+type request struct {
+	event      Event
+	detail     string
+	hold       time.Duration
+	generation int
+	done       chan bool
+}
+
+// NewController creates a controller in the Idle phase; call Run to start it
+func NewController(hw Hardware, clock Clock, timings Timings) *Controller {
+	return &Controller{
+		hw:      hw,
+		clock:   clock,
+		timings: timings,
+		events:  make(chan request),
+		phase:   Idle,
+	}
+}
+
+// Observe adds an observer of transitions
+func (c *Controller) Observe(o Observer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observers = append(c.observers, o)
+}
+
+// Run handles events until the process exits
+func (c *Controller) Run() {
+	for r := range c.events {
+		r.done <- c.handle(r)
+	}
+}
+
+// Phase returns the current phase
+func (c *Controller) Phase() Phase {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.phase
+}
+
+// Verify starts verifying an entered code, returning false if the door is not
+// accepting codes
+func (c *Controller) Verify() bool {
+	return c.post(request{event: CodeEntered})
+}
+
+// Grant unlocks the door for the code being verified
+func (c *Controller) Grant() bool {
+	return c.post(request{event: CodeGranted})
+}
+
+// Deny rejects the code being verified
+func (c *Controller) Deny() bool {
+	return c.post(request{event: CodeDenied})
+}
+
+// Cancel returns to idle without a decision on the code being verified
+func (c *Controller) Cancel() bool {
+	return c.post(request{event: CodeCancelled})
+}
+
+// Override unlocks the door unless it is already open
+func (c *Controller) Override(source string) bool {
+	return c.post(request{event: OverrideUsed, detail: source})
+}
+
+// Hold unlocks the door and keeps it unlocked for the duration, unlocking it
+// again each time it closes until the hold expires
+func (c *Controller) Hold(source string, d time.Duration) bool {
+	return c.post(request{event: OverrideHeld, detail: source, hold: d})
+}
+
+// Contact reports a change of the door contact
+func (c *Controller) Contact(state ContactState) bool {
+	if state == Open {
+		return c.post(request{event: ContactOpened})
+	}
+	return c.post(request{event: ContactClosed})
+}
+
+// Fault reports that the door contact can no longer be read
+func (c *Controller) Fault(err error) bool {
+	return c.post(request{event: SensorFault, detail: err.Error()})
+}
+
+// Restore reports that the door contact can be read again
+func (c *Controller) Restore() bool {
+	return c.post(request{event: SensorRestored})
+}
+
+func (c *Controller) post(r request) bool {
+	r.done = make(chan bool, 1)
+	c.events <- r
+	return <-r.done
+}
+
+func (c *Controller) handle(r request) bool {
+	phase := c.Phase()
+	switch r.event {
+	case CodeEntered:
+		if phase == Idle || phase == Rejected || phase == Verifying {
+			c.hw.Wait()
+			return c.enter(Verifying, r, c.timings.Verify)
+		}
+	case CodeGranted:
+		if phase == Verifying {
+			c.hw.Unlock()
+			return c.enter(Unlocked, r, c.unlockedTimeout())
+		}
+	case CodeDenied:
+		if phase == Verifying {
+			c.hw.Reject()
+			return c.enter(Rejected, r, c.timings.Reject)
+		}
+	case CodeCancelled:
+		if phase == Verifying {
+			c.hw.Lock()
+			return c.enter(Idle, r, 0)
+		}
+	case OverrideUsed:
+		if phase != Opened && phase != HeldOpen {
+			c.hw.Unlock()
+			return c.enter(Unlocked, r, c.unlockedTimeout())
+		}
+	case OverrideHeld:
+		c.holdUntil = c.clock.Now().Add(r.hold)
+		if phase == Opened || phase == HeldOpen {
+			return c.enter(Opened, r, c.openedTimeout())
+		}
+		c.hw.Unlock()
+		return c.enter(Unlocked, r, c.unlockedTimeout())
+	case ContactOpened:
+		if phase == Unlocked {
+			return c.enter(Opened, r, c.openedTimeout())
+		}
+	case ContactClosed:
+		if phase == Opened || phase == HeldOpen {
+			if c.holding() {
+				c.hw.Unlock()
+				return c.enter(Unlocked, r, c.unlockedTimeout())
+			}
+			c.hw.Lock()
+			return c.enter(Idle, r, 0)
+		}
+	case SensorFault:
+		if phase != Fault {
+			c.hw.Lock()
+			return c.enter(Fault, r, 0)
+		}
+	case SensorRestored:
+		if phase == Fault {
+			c.hw.Lock()
+			return c.enter(Idle, r, 0)
+		}
+	case TimedOut:
+		if r.generation != c.generation {
+			return false
+		}
+		return c.timedOut(phase, r)
+	}
+	return false
+}
+
+func (c *Controller) timedOut(phase Phase, r request) bool {
+	switch phase {
+	case Unlocked:
+		state, err := c.hw.Contact()
+		if err != nil {
+			c.hw.Lock()
+			r.detail = err.Error()
+			return c.enter(Fault, r, 0)
+		}
+		if state == Open {
+			return c.enter(Opened, r, c.openedTimeout())
+		}
+		if !c.holdUntil.IsZero() {
+			r.event = HoldExpired
+		}
+		c.hw.Lock()
+		return c.enter(Idle, r, 0)
+	case Opened:
+		c.hw.Lock()
+		return c.enter(HeldOpen, r, 0)
+	case Rejected, Verifying:
+		c.hw.Lock()
+		return c.enter(Idle, r, 0)
+	}
+	return false
+}
+
+func (c *Controller) holding() bool {
+	return c.clock.Now().Before(c.holdUntil)
+}
+
+// unlockedTimeout is how long to wait for the door to open, or the rest of a
+// hold if longer
+func (c *Controller) unlockedTimeout() time.Duration {
+	return c.extendForHold(c.timings.OpenWait)
+}
+
+// openedTimeout is how long the door may be open, or the rest of a hold if
+// longer
+func (c *Controller) openedTimeout() time.Duration {
+	return c.extendForHold(c.timings.HeldOpen)
+}
+
+func (c *Controller) extendForHold(d time.Duration) time.Duration {
+	if remaining := c.holdUntil.Sub(c.clock.Now()); remaining > d {
+		return remaining
+	}
+	return d
+}
+
+// enter moves to the phase, replacing any pending timeout with a new one if
+// given
+func (c *Controller) enter(to Phase, r request, timeout time.Duration) bool {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.generation++
+	if to == Idle || to == Fault {
+		c.holdUntil = time.Time{}
+	}
+	if timeout > 0 {
+		generation := c.generation
+		c.timer = c.clock.AfterFunc(timeout, func() {
+			c.post(request{event: TimedOut, generation: generation})
+		})
+	}
+
+	c.mu.Lock()
+	t := Transition{From: c.phase, To: to, Event: r.event, At: c.clock.Now(), Detail: r.detail}
+	c.phase = to
+	observers := c.observers
+	c.mu.Unlock()
+
+	log.Printf("DOOR: %v -> %v (%v)\n", t.From, t.To, t.Event)
+	for _, o := range observers {
+		o(t)
+	}
+	return true
+}
@@ -0,0 +1,249 @@
+package door
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeHardware struct {
+	mu      sync.Mutex
+	calls   []string
+	contact ContactState
+	err     error
+}
+
+func (h *fakeHardware) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func (h *fakeHardware) last() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.calls) == 0 {
+		return ""
+	}
+	return h.calls[len(h.calls)-1]
+}
+
+func (h *fakeHardware) Unlock() { h.record("unlock") }
+func (h *fakeHardware) Lock()   { h.record("lock") }
+func (h *fakeHardware) Reject() { h.record("reject") }
+func (h *fakeHardware) Wait()   { h.record("wait") }
+
+func (h *fakeHardware) Contact() (ContactState, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.contact, h.err
+}
+
+var timings = Timings{
+	OpenWait: 60 * time.Second,
+	HeldOpen: 60 * time.Second,
+	Reject:   3 * time.Second,
+	Verify:   15 * time.Second,
+}
+
+func TestOpenAndClose(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	if !c.Verify() || c.Phase() != Verifying || hw.last() != "wait" {
+		t.Errorf("Expected verifying, got %v\n", c.Phase())
+	}
+	c.Grant()
+	if c.Phase() != Unlocked || hw.last() != "unlock" {
+		t.Errorf("Expected unlocked, got %v\n", c.Phase())
+	}
+	if c.Verify() {
+		t.Errorf("Expected codes to be ignored while unlocked\n")
+	}
+	clock.Advance(30 * time.Second)
+	c.Contact(Open)
+	if c.Phase() != Opened {
+		t.Errorf("Expected open, got %v\n", c.Phase())
+	}
+	clock.Advance(45 * time.Second)
+	if c.Phase() != Opened {
+		t.Errorf("Expected open wait to have been cancelled, got %v\n", c.Phase())
+	}
+	c.Contact(Closed)
+	if c.Phase() != Idle || hw.last() != "lock" {
+		t.Errorf("Expected idle and locked, got %v\n", c.Phase())
+	}
+
+	expected := []Phase{Verifying, Unlocked, Opened, Idle}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected %v transitions, got %v\n", len(expected), transitions)
+	}
+	for i, phase := range expected {
+		if transitions[i].To != phase {
+			t.Errorf("Expected transition %v to %v, got %v\n", i, phase, transitions[i])
+		}
+	}
+	if !transitions[2].At.Equal(clock.Now().Add(-45 * time.Second)) {
+		t.Errorf("Expected transition time from the clock, got %v\n", transitions[2].At)
+	}
+}
+
+func TestNeverOpened(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	c.Verify()
+	c.Grant()
+	clock.Advance(60 * time.Second)
+	last := transitions[len(transitions)-1]
+	if c.Phase() != Idle || hw.last() != "lock" || last.From != Unlocked || last.Event != TimedOut {
+		t.Errorf("Expected timeout back to idle, got %v\n", last)
+	}
+}
+
+func TestOpenedBeforeTimeout(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	c.Override("button")
+	hw.mu.Lock()
+	hw.contact = Open
+	hw.mu.Unlock()
+	clock.Advance(60 * time.Second)
+	if c.Phase() != Opened {
+		t.Errorf("Expected open door to be found on timeout, got %v\n", c.Phase())
+	}
+	clock.Advance(60 * time.Second)
+	if c.Phase() != HeldOpen {
+		t.Errorf("Expected held open, got %v\n", c.Phase())
+	}
+	if c.Override("sqs") {
+		t.Errorf("Expected override to be ignored while open\n")
+	}
+	c.Contact(Closed)
+	if c.Phase() != Idle {
+		t.Errorf("Expected idle, got %v\n", c.Phase())
+	}
+}
+
+func TestRejected(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	c.Verify()
+	c.Deny()
+	if c.Phase() != Rejected || hw.last() != "reject" {
+		t.Errorf("Expected rejected, got %v\n", c.Phase())
+	}
+	clock.Advance(2 * time.Second)
+	c.Verify()
+	c.Deny()
+	clock.Advance(2 * time.Second)
+	if c.Phase() != Rejected {
+		t.Errorf("Expected first rejection timeout to be superseded, got %v\n", c.Phase())
+	}
+	clock.Advance(time.Second)
+	if c.Phase() != Idle || hw.last() != "lock" {
+		t.Errorf("Expected idle, got %v\n", c.Phase())
+	}
+}
+
+func TestAbandoned(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	// a partial code, then the keypad is cleared
+	c.Verify()
+	clock.Advance(10 * time.Second)
+	c.Verify()
+	clock.Advance(10 * time.Second)
+	if c.Phase() != Verifying || hw.last() != "wait" {
+		t.Errorf("Expected each key to keep verifying, got %v\n", c.Phase())
+	}
+	clock.Advance(5 * time.Second)
+	last := transitions[len(transitions)-1]
+	if c.Phase() != Idle || hw.last() != "lock" || last.Event != TimedOut {
+		t.Errorf("Expected an abandoned code to return to idle, got %v\n", last)
+	}
+}
+
+func TestFault(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	c.Fault(errors.New("contact unreadable"))
+	if c.Phase() != Fault || transitions[0].Detail != "contact unreadable" {
+		t.Errorf("Expected fault, got %v\n", c.Phase())
+	}
+	if c.Verify() {
+		t.Errorf("Expected codes to be ignored while faulted\n")
+	}
+	c.Restore()
+	if c.Phase() != Idle {
+		t.Errorf("Expected idle, got %v\n", c.Phase())
+	}
+}
+
+func TestHold(t *testing.T) {
+	hw := &fakeHardware{contact: Closed}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	c := NewController(hw, clock, timings)
+	transitions := []Transition{}
+	c.Observe(func(t Transition) { transitions = append(transitions, t) })
+	go c.Run()
+
+	c.Hold("button", 10*time.Minute)
+	if c.Phase() != Unlocked {
+		t.Errorf("Expected unlocked, got %v\n", c.Phase())
+	}
+	clock.Advance(2 * time.Minute)
+	if c.Phase() != Unlocked {
+		t.Errorf("Expected still unlocked during hold, got %v\n", c.Phase())
+	}
+	c.Contact(Open)
+	clock.Advance(2 * time.Minute)
+	if c.Phase() != Opened {
+		t.Errorf("Expected open without held open alert during hold, got %v\n", c.Phase())
+	}
+	c.Contact(Closed)
+	if c.Phase() != Unlocked || hw.last() != "unlock" {
+		t.Errorf("Expected unlocked again on close during hold, got %v\n", c.Phase())
+	}
+	clock.Advance(6 * time.Minute)
+	last := transitions[len(transitions)-1]
+	if c.Phase() != Idle || last.Event != HoldExpired {
+		t.Errorf("Expected hold to expire, got %v\n", last)
+	}
+
+	c.Override("button")
+	clock.Advance(60 * time.Second)
+	last = transitions[len(transitions)-1]
+	if last.Event != TimedOut {
+		t.Errorf("Expected a normal timeout after the hold, got %v\n", last)
+	}
+}
@@ -1,66 +1,117 @@
 package door
 
 import (
-	"fmt"
 	"log"
-	"time"
-
-	"github.com/luismesas/goPi/piface"
-	"github.com/luismesas/goPi/spi"
 )
 
-type inputPin int
-type outputPin int
+type output string
+type input string
 type ContactState bool
 
 const (
-	red       outputPin = 4
-	green     outputPin = 5
-	blue      outputPin = 6
-	white     outputPin = 7
-	latch     outputPin = 3
-	lights    outputPin = 2
-	wallLight outputPin = 1
-
-	contact  inputPin = 0
-	override inputPin = 1
+	red       output = "red"
+	green     output = "green"
+	blue      output = "blue"
+	white     output = "white"
+	latch     output = "latch"
+	lights    output = "lights"
+	wallLight output = "wall_light"
+	siren     output = "siren"
+
+	contact    input = ContactInput
+	override   input = OverrideInput
+	latchSense input = "latch_sense"
 
 	Open   ContactState = true
 	Closed ContactState = false
-
-	// when door is open
-	contactOpen = 0
-
-	// when door is closed
-	manualOverrideEngaged = 0
 )
 
+var outputs = []output{red, green, blue, white, latch, lights, wallLight, siren}
+var inputs = []input{contact, override, latchSense}
+
 var locked = true
-var pfd *piface.PiFaceDigital
-var openFn func(string)
+var board Driver
+var actuators map[output]Actuator
+var sensors map[input]Sensor
+var watcher *Watcher
+var doorLatch *Latch
+var indicator *Indicator
 var darkOutsideInHours = false
 var darkOutside = false
 var lightsOnOveride = false
 
-// Initialise x
-func Initialise(overrideFn func(string)) error {
-	openFn = overrideFn
-	pfd = piface.NewPiFaceDigital(spi.DEFAULT_HARDWARE_ADDR, spi.DEFAULT_BUS, spi.DEFAULT_CHIP)
-	err := pfd.InitBoard()
-	if err != nil {
-		fmt.Printf("DOOR: Error on init board: %s", err)
-		return err
+// Initialise opens the door's outputs and inputs on the driver's pins, and
+// starts watching the inputs
+func Initialise(drv Driver, pins Pins, debounce Debounce, latchProfile LatchProfile) error {
+	board = drv
+	actuators = map[output]Actuator{}
+	sensors = map[input]Sensor{}
+	for _, o := range outputs {
+		a, err := openOutput(drv, pins[string(o)])
+		if err != nil {
+			return err
+		}
+		actuators[o] = a
+	}
+	for _, i := range inputs {
+		pin := pins[string(i)]
+		if pin.Line < 0 {
+			continue
+		}
+		s, err := openInput(drv, pin)
+		if err != nil {
+			return err
+		}
+		sensors[i] = s
+	}
+	doorLatch = NewLatch(SystemClock{}, latchProfile, actuators[latch])
+	indicator = NewIndicator(SystemClock{}, Board{})
+	for _, o := range []output{red, green, blue, siren} {
+		off(o)
 	}
 	Lock()
-	go checkOverride()
+	watcher = NewWatcher(SystemClock{}, debounce, sensors[contact], sensors[override])
+	go watchLatch(watcher.Subscribe())
+	go watcher.Run()
 	return nil
 }
 
+// MonitorLatch creates a monitor of the door's latch, using the latch sense
+// input if one is fitted
+func MonitorLatch(clock Clock, check LatchCheck, faultFn func(failures int, detail string)) *LatchMonitor {
+	return NewLatchMonitor(clock, check, doorLatch, sensors[latchSense], faultFn)
+}
+
+// Indicators returns the display of the door's LEDs
+func Indicators() *Indicator {
+	return indicator
+}
+
+// Subscribe returns a channel of the debounced edges of the door's inputs
+func Subscribe() <-chan Edge {
+	return watcher.Subscribe()
+}
+
+// Close releases the driver
+func Close() error {
+	return board.Close()
+}
+
 func State() ContactState {
-	if pfd.Switches[contact].Value() == contactOpen {
-		return Open
+	state, err := ReadState()
+	if err != nil {
+		log.Printf("DOOR: Error reading %v: %v\n", contact, err)
 	}
-	return Closed
+	return state
+}
+
+// ReadState reads the door contact, returning any error from the sensor
+func ReadState() (ContactState, error) {
+	open, err := sensors[contact].Active()
+	if err != nil {
+		return Closed, err
+	}
+	return ContactState(open), nil
 }
 
 // Locked x
@@ -71,19 +122,13 @@ func Locked() bool {
 // Unlock x
 func Unlock() {
 	log.Println("DOOR: Latch activated")
-	log.Println("DOOR: LED: Green")
-	pfd.Leds[red].AllOff()
-	pfd.Leds[blue].AllOff()
-	pfd.Leds[white].AllOff()
-
-	pfd.Leds[green].AllOn()
+	off(white)
+	indicator.Show(FeedbackLayer, FeedbackPriority, AcceptedPattern, 0)
 	if darkOutside || lightsOnOveride {
-		pfd.Leds[lights].AllOn()
+		on(lights)
 	}
 
-	pfd.Leds[latch].AllOn()
-	time.Sleep(1 * time.Second)
-	pfd.Leds[latch].AllOff()
+	doorLatch.Release()
 	locked = false
 	return
 }
@@ -91,40 +136,31 @@ func Unlock() {
 // Reject x
 func Reject() {
 	log.Println("DOOR: LED: Red")
-	pfd.Leds[green].AllOff()
-	pfd.Leds[blue].AllOff()
-	pfd.Leds[white].AllOff()
-	pfd.Leds[latch].AllOff()
-
-	pfd.Leds[red].AllOn()
+	off(white)
+	doorLatch.Engage()
+	indicator.Show(FeedbackLayer, FeedbackPriority, RejectedPattern, 0)
 	return
 }
 
 // Wait x
 func Wait() {
 	log.Println("DOOR: LED: Blue")
-	pfd.Leds[red].AllOff()
-	pfd.Leds[green].AllOff()
-	// pfd.Leds[white].AllOff()
-	pfd.Leds[latch].AllOff()
-
-	pfd.Leds[blue].AllOn()
+	doorLatch.Engage()
+	indicator.Show(FeedbackLayer, FeedbackPriority, VerifyingPattern, 0)
 	return
 }
 
 // Lock x
 func Lock() {
 	log.Println("DOOR: Locked")
-	pfd.Leds[red].AllOff()
-	pfd.Leds[green].AllOff()
-	pfd.Leds[blue].AllOff()
-	pfd.Leds[latch].AllOff()
+	doorLatch.Engage()
 	if !lightsOnOveride {
-		pfd.Leds[lights].AllOff()
+		off(lights)
 	}
 
-	resetToLight()
 	locked = true
+	indicator.Clear(FeedbackLayer)
+	resetToLight()
 	return
 }
 
@@ -139,36 +175,53 @@ func SetDarkOutside(darkInHours, dark bool) {
 func resetToLight() {
 	if darkOutsideInHours || lightsOnOveride {
 		//log.Println("DOOR: LED: White")
-		pfd.Leds[white].AllOn()
-		pfd.Leds[wallLight].AllOn()
+		on(white)
+		on(wallLight)
 	} else {
 		// log.Println("DOOR: LED: Off")
-		pfd.Leds[white].AllOff()
-		pfd.Leds[wallLight].AllOff()
+		off(white)
+		off(wallLight)
 	}
 }
 
-// This does not work properly
-func checkOverride() {
-	for {
-		if pfd.Switches[override].Value() == manualOverrideEngaged && locked {
-			log.Println("DOOR: Manual override, unlocking")
-			openFn("button")
+func watchLatch(edges <-chan Edge) {
+	for edge := range edges {
+		if edge.Kind == DoorOpened {
+			doorLatch.Opened()
 		}
-		time.Sleep(200 * time.Millisecond)
 	}
 }
 
 func LightsOn() {
 	lightsOnOveride = true
-	pfd.Leds[white].AllOn()
-	pfd.Leds[wallLight].AllOn()
-	pfd.Leds[lights].AllOn()
+	on(white)
+	on(wallLight)
+	on(lights)
 }
 
 func LightsOff() {
 	lightsOnOveride = false
-	pfd.Leds[white].AllOff()
-	pfd.Leds[wallLight].AllOff()
-	pfd.Leds[lights].AllOff()
+	off(white)
+	off(wallLight)
+	off(lights)
+}
+
+func on(o output) {
+	if err := actuators[o].Set(true); err != nil {
+		log.Printf("DOOR: Error setting %v on: %v\n", o, err)
+	}
+}
+
+func off(o output) {
+	if err := actuators[o].Set(false); err != nil {
+		log.Printf("DOOR: Error setting %v off: %v\n", o, err)
+	}
+}
+
+func active(i input) bool {
+	a, err := sensors[i].Active()
+	if err != nil {
+		log.Printf("DOOR: Error reading %v: %v\n", i, err)
+	}
+	return a
 }
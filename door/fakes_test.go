@@ -0,0 +1,91 @@
+package door
+
+import (
+	"sync"
+	"time"
+)
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+// Advance moves the clock on, firing any timers that fall due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	due := []*fakeTimer{}
+	pending := []*fakeTimer{}
+	for _, t := range c.timers {
+		if !t.at.After(c.now) {
+			due = append(due, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+	for _, t := range due {
+		if !t.stopped {
+			t.stopped = true
+			t.f()
+		}
+	}
+}
+
+type fakeSensor struct {
+	active bool
+	err    error
+}
+
+func (s *fakeSensor) Active() (bool, error) {
+	return s.active, s.err
+}
+
+type fakeOutputs struct {
+	levels   map[string]bool
+	restores int
+}
+
+func (o *fakeOutputs) Set(name string, on bool) { o.levels[name] = on }
+func (o *fakeOutputs) Restore()                 { o.restores++ }
+
+type fakeActuator struct {
+	on      bool
+	changes int
+}
+
+func (a *fakeActuator) Set(on bool) error {
+	if a.on != on {
+		a.changes++
+	}
+	a.on = on
+	return nil
+}
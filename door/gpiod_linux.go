@@ -0,0 +1,120 @@
+package door
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	defaultGpioChip = "/dev/gpiochip0"
+
+	gpioHandleRequestInput  = 1 << 0
+	gpioHandleRequestOutput = 1 << 1
+	gpioHandlesMax          = 64
+	gpioConsumer            = "parceldrop"
+)
+
+// gpioHandleRequest is the kernel's struct gpiohandle_request, from the v1
+// GPIO character device ABI in linux/gpio.h
+type gpioHandleRequest struct {
+	LineOffsets   [gpioHandlesMax]uint32
+	Flags         uint32
+	DefaultValues [gpioHandlesMax]uint8
+	ConsumerLabel [32]byte
+	Lines         uint32
+	Fd            int32
+}
+
+// gpioHandleData is the kernel's struct gpiohandle_data
+type gpioHandleData struct {
+	Values [gpioHandlesMax]uint8
+}
+
+var (
+	gpioGetLineHandleIoctl       = iowr(0xB4, 0x03, unsafe.Sizeof(gpioHandleRequest{}))
+	gpioHandleGetLineValuesIoctl = iowr(0xB4, 0x08, unsafe.Sizeof(gpioHandleData{}))
+	gpioHandleSetLineValuesIoctl = iowr(0xB4, 0x09, unsafe.Sizeof(gpioHandleData{}))
+)
+
+// gpiodDriver drives lines through the Linux GPIO character device
+type gpiodDriver struct {
+	chip  *os.File
+	lines []*os.File
+}
+
+type gpiodLine struct {
+	handle *os.File
+}
+
+func openGpiod(chip string) (Driver, error) {
+	f, err := os.OpenFile(chip, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("DOOR: open GPIO chip: %s", err)
+	}
+	return &gpiodDriver{chip: f}, nil
+}
+
+func (d *gpiodDriver) Output(pin Pin) (Actuator, error) {
+	return d.request(pin, gpioHandleRequestOutput)
+}
+
+func (d *gpiodDriver) Input(pin Pin) (Sensor, error) {
+	return d.request(pin, gpioHandleRequestInput)
+}
+
+// request requests the pin's line, an output being driven at its inactive
+// level from the moment it is requested, so that an active low latch is never
+// released before it is first locked
+func (d *gpiodDriver) request(pin Pin, flags uint32) (*gpiodLine, error) {
+	line := pin.Line
+	req := gpioHandleRequest{Flags: flags, Lines: 1}
+	req.LineOffsets[0] = uint32(line)
+	if pin.ActiveLow {
+		req.DefaultValues[0] = 1
+	}
+	copy(req.ConsumerLabel[:], gpioConsumer)
+	err := ioctl(d.chip.Fd(), gpioGetLineHandleIoctl, unsafe.Pointer(&req))
+	if err != nil {
+		return nil, fmt.Errorf("DOOR: request GPIO line %v: %s", line, err)
+	}
+	handle := os.NewFile(uintptr(req.Fd), fmt.Sprintf("%s:%d", d.chip.Name(), line))
+	d.lines = append(d.lines, handle)
+	return &gpiodLine{handle: handle}, nil
+}
+
+func (d *gpiodDriver) Close() error {
+	for _, line := range d.lines {
+		line.Close()
+	}
+	d.lines = nil
+	return d.chip.Close()
+}
+
+func (l *gpiodLine) Set(on bool) error {
+	var data gpioHandleData
+	if on {
+		data.Values[0] = 1
+	}
+	return ioctl(l.handle.Fd(), gpioHandleSetLineValuesIoctl, unsafe.Pointer(&data))
+}
+
+func (l *gpiodLine) Active() (bool, error) {
+	var data gpioHandleData
+	err := ioctl(l.handle.Fd(), gpioHandleGetLineValuesIoctl, unsafe.Pointer(&data))
+	return data.Values[0] != 0, err
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// iowr is the kernel's _IOWR macro
+func iowr(t, nr, size uintptr) uintptr {
+	return 3<<30 | size<<16 | t<<8 | nr
+}
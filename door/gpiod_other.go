@@ -0,0 +1,11 @@
+//go:build !linux
+
+package door
+
+import "errors"
+
+const defaultGpioChip = "/dev/gpiochip0"
+
+func openGpiod(chip string) (Driver, error) {
+	return nil, errors.New("DOOR: gpiod driver is only supported on Linux")
+}
@@ -0,0 +1,141 @@
+package door
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Actuator drives a digital output, on being active
+type Actuator interface {
+	Set(on bool) error
+}
+
+// Sensor reads a digital input
+type Sensor interface {
+	Active() (bool, error)
+}
+
+// Driver opens the lines of an I/O board by the Pin's number. Its actuators
+// and sensors are active high, with polarity applied on top, but a driver that
+// can should start the line at the pin's inactive level.
+type Driver interface {
+	Output(pin Pin) (Actuator, error)
+	Input(pin Pin) (Sensor, error)
+	Close() error
+}
+
+// Pin is the line a door output or input is wired to, a negative line being
+// an optional input that is not fitted
+type Pin struct {
+	Line      int
+	ActiveLow bool
+}
+
+// Pins maps the door's outputs and inputs by name to the lines of the board
+type Pins map[string]Pin
+
+// DefaultPins is the wiring of the original PiFace build. The contact reads
+// low when the door is open, and the override low when pressed.
+func DefaultPins() Pins {
+	return Pins{
+		string(siren):     {Line: 0},
+		string(wallLight): {Line: 1},
+		string(lights):    {Line: 2},
+		string(latch):     {Line: 3},
+		string(red):       {Line: 4},
+		string(green):     {Line: 5},
+		string(blue):      {Line: 6},
+		string(white):     {Line: 7},
+		string(contact):   {Line: 0, ActiveLow: true},
+		string(override):  {Line: 1, ActiveLow: true},
+		// active when the latch is released, not fitted by default
+		string(latchSense): {Line: -1},
+	}
+}
+
+// ParsePins overrides the default pins from a comma separated list of
+// name=line entries, each optionally suffixed with :low for an active low pin,
+// e.g. "latch=17,contact=27:low"
+func ParsePins(spec string) (Pins, error) {
+	pins := DefaultPins()
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("DOOR: invalid pin: %q", entry)
+		}
+		if _, known := pins[name]; !known {
+			return nil, fmt.Errorf("DOOR: unknown pin: %q", name)
+		}
+		line, polarity, _ := strings.Cut(value, ":")
+		pin := Pin{}
+		var err error
+		if pin.Line, err = strconv.Atoi(line); err != nil || pin.Line < 0 {
+			return nil, fmt.Errorf("DOOR: invalid line for pin %v: %q", name, line)
+		}
+		switch polarity {
+		case "", "high":
+		case "low":
+			pin.ActiveLow = true
+		default:
+			return nil, fmt.Errorf("DOOR: invalid polarity for pin %v: %q", name, polarity)
+		}
+		pins[name] = pin
+	}
+	return pins, nil
+}
+
+// OpenDriver opens the named driver: piface (the default), gpiod on the given
+// GPIO character device, or sim
+func OpenDriver(name, chip string) (Driver, error) {
+	switch name {
+	case "", "piface":
+		return openPiFace()
+	case "gpiod":
+		if chip == "" {
+			chip = defaultGpioChip
+		}
+		return openGpiod(chip)
+	case "sim":
+		return NewSimulator(), nil
+	default:
+		return nil, fmt.Errorf("DOOR: unknown driver: %q", name)
+	}
+}
+
+type activeLowActuator struct {
+	Actuator
+}
+
+func (a activeLowActuator) Set(on bool) error {
+	return a.Actuator.Set(!on)
+}
+
+type activeLowSensor struct {
+	Sensor
+}
+
+func (s activeLowSensor) Active() (bool, error) {
+	active, err := s.Sensor.Active()
+	return !active, err
+}
+
+func openOutput(drv Driver, pin Pin) (Actuator, error) {
+	a, err := drv.Output(pin)
+	if err != nil || !pin.ActiveLow {
+		return a, err
+	}
+	return activeLowActuator{a}, nil
+}
+
+func openInput(drv Driver, pin Pin) (Sensor, error) {
+	s, err := drv.Input(pin)
+	if err != nil || !pin.ActiveLow {
+		return s, err
+	}
+	return activeLowSensor{s}, nil
+}
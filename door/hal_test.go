@@ -0,0 +1,67 @@
+package door
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePins(t *testing.T) {
+	pins, err := ParsePins("latch=17, contact=27:low,override=22:high")
+	if err != nil {
+		t.Errorf("Expected no error, got %v\n", err)
+	}
+	if pins["latch"] != (Pin{Line: 17}) {
+		t.Errorf("Expected latch on 17, got %v\n", pins["latch"])
+	}
+	if pins["contact"] != (Pin{Line: 27, ActiveLow: true}) {
+		t.Errorf("Expected active low contact on 27, got %v\n", pins["contact"])
+	}
+	if pins["override"] != (Pin{Line: 22}) {
+		t.Errorf("Expected active high override on 22, got %v\n", pins["override"])
+	}
+	if pins["red"] != (Pin{Line: 4}) {
+		t.Errorf("Expected default red on 4, got %v\n", pins["red"])
+	}
+}
+
+func TestParsePinsInvalid(t *testing.T) {
+	for _, spec := range []string{"latch", "door=3", "latch=x", "latch=-1", "latch=3:sideways"} {
+		if _, err := ParsePins(spec); err == nil {
+			t.Errorf("Expected error for %q\n", spec)
+		}
+	}
+}
+
+func TestSimulatedDoor(t *testing.T) {
+	sim := NewSimulator()
+	pins, _ := ParsePins("latch=3:low")
+	if err := Initialise(sim, pins, DefaultDebounce, DefaultLatch); err != nil {
+		t.Errorf("Expected no error, got %v\n", err)
+	}
+
+	if !sim.OutputLevel(3) {
+		t.Errorf("Expected active low latch to be held high when locked\n")
+	}
+	if State() != Closed {
+		t.Errorf("Expected door closed\n")
+	}
+	if pressed, _ := sensors[override].Active(); pressed {
+		t.Errorf("Expected override released\n")
+	}
+	sim.Control(strings.NewReader("contact active\nlatch active\nbell active\n"), pins)
+	if State() != Open {
+		t.Errorf("Expected door open\n")
+	}
+	if sim.inputs[3] {
+		t.Errorf("Expected no input set for an output\n")
+	}
+
+	Reject()
+	if !sim.OutputLevel(4) || sim.OutputLevel(5) {
+		t.Errorf("Expected red on and green off\n")
+	}
+	Wait()
+	if sim.OutputLevel(4) || !sim.OutputLevel(6) {
+		t.Errorf("Expected red off and blue on\n")
+	}
+}
@@ -0,0 +1,278 @@
+package door
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PatternStep turns on a set of outputs for a duration
+type PatternStep struct {
+	Outputs  []string
+	Duration time.Duration
+}
+
+// Pattern is a sequence of steps repeated for as long as it is shown. A
+// pattern of a single step is shown solid.
+type Pattern []PatternStep
+
+// Priority orders the patterns shown at once, only the highest being seen
+type Priority int
+
+const (
+	StatusPriority   Priority = 10
+	FeedbackPriority Priority = 20
+	AlertPriority    Priority = 30
+	AlarmPriority    Priority = 40
+
+	// FeedbackLayer is the keypad's response to the code being entered
+	FeedbackLayer = "feedback"
+	LockoutLayer  = "lockout"
+	OfflineLayer  = "offline"
+	FaultLayer    = "fault"
+	KeypadLayer   = "keypad"
+)
+
+// Solid turns the outputs on for as long as it is shown
+func Solid(outputs ...string) Pattern {
+	return Pattern{{Outputs: outputs, Duration: time.Hour}}
+}
+
+// Blink turns the outputs on and off evenly over the period
+func Blink(period time.Duration, outputs ...string) Pattern {
+	return Pattern{{Outputs: outputs, Duration: period / 2}, {Duration: period / 2}}
+}
+
+// Pulse turns the outputs on briefly, then off for longer
+func Pulse(on, off time.Duration, outputs ...string) Pattern {
+	return Pattern{{Outputs: outputs, Duration: on}, {Duration: off}}
+}
+
+var (
+	VerifyingPattern = Solid(string(blue))
+	RejectedPattern  = Solid(string(red))
+	// AcceptedPattern asks for the door to be pulled now
+	AcceptedPattern = Blink(500*time.Millisecond, string(green))
+	// ExpiringPattern is accepted, alternating with blue for a code that is
+	// close to expiry
+	ExpiringPattern = Pattern{
+		{Outputs: []string{string(green)}, Duration: 400 * time.Millisecond},
+		{Outputs: []string{string(blue)}, Duration: 200 * time.Millisecond},
+	}
+	LockoutPattern = Pulse(200*time.Millisecond, 800*time.Millisecond, string(red))
+	OfflinePattern = Pulse(100*time.Millisecond, 1900*time.Millisecond, string(blue))
+	FaultPattern   = Pattern{
+		{Outputs: []string{string(red)}, Duration: 250 * time.Millisecond},
+		{Outputs: []string{string(blue)}, Duration: 250 * time.Millisecond},
+	}
+)
+
+// ParsePattern reads a pattern from a comma separated list of steps, each a
+// set of outputs joined by + and the duration they are on for, e.g.
+// "red+siren:250ms,off:250ms". A step of "off" turns every output off. The
+// latch cannot be part of a pattern.
+func ParsePattern(spec string) (Pattern, error) {
+	known := map[string]bool{}
+	for _, o := range outputs {
+		known[string(o)] = o != latch
+	}
+	pattern := Pattern{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		names, duration, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("DOOR: invalid pattern step: %q", entry)
+		}
+		step := PatternStep{}
+		var err error
+		if step.Duration, err = time.ParseDuration(duration); err != nil || step.Duration <= 0 {
+			return nil, fmt.Errorf("DOOR: invalid pattern duration: %q", duration)
+		}
+		if names != "off" {
+			for _, name := range strings.Split(names, "+") {
+				if !known[name] {
+					return nil, fmt.Errorf("DOOR: unknown output in pattern: %q", name)
+				}
+				step.Outputs = append(step.Outputs, name)
+			}
+		}
+		pattern = append(pattern, step)
+	}
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("DOOR: empty pattern")
+	}
+	return pattern, nil
+}
+
+// Display shows patterns in named layers
+type Display interface {
+	// Show replaces the layer's pattern, for the duration or until cleared
+	// if zero
+	Show(name string, priority Priority, pattern Pattern, d time.Duration)
+	Clear(name string)
+}
+
+// Outputs are the named outputs a pattern is shown on
+type Outputs interface {
+	Set(name string, on bool)
+	// Restore returns the outputs to their state when no pattern is shown
+	Restore()
+}
+
+// Set turns a named output on or off
+func (Board) Set(name string, active bool) {
+	if active {
+		on(output(name))
+	} else {
+		off(output(name))
+	}
+}
+
+// Restore resets the lights of a locked door
+func (Board) Restore() {
+	if locked {
+		resetToLight()
+	}
+}
+
+// Indicator is a Display that shows the highest priority of its layers on the
+// outputs, the most recently shown winning a tie
+type Indicator struct {
+	clock   Clock
+	outputs Outputs
+
+	mu         sync.Mutex
+	layers     map[string]*layer
+	shown      *layer
+	sequence   int
+	timer      Timer
+	generation int
+}
+
+type layer struct {
+	name     string
+	priority Priority
+	pattern  Pattern
+	sequence int
+	expiry   Timer
+}
+
+// NewIndicator creates an indicator showing nothing
+func NewIndicator(clock Clock, outputs Outputs) *Indicator {
+	return &Indicator{clock: clock, outputs: outputs, layers: map[string]*layer{}}
+}
+
+// Show shows the pattern on the named layer, replacing whatever it showed, for
+// d or until cleared if d is 0. Only the highest priority layer is shown.
+func (i *Indicator) Show(name string, priority Priority, pattern Pattern, d time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if previous, ok := i.layers[name]; ok && previous.expiry != nil {
+		previous.expiry.Stop()
+	}
+	i.sequence++
+	l := &layer{name: name, priority: priority, pattern: pattern, sequence: i.sequence}
+	if d > 0 {
+		l.expiry = i.clock.AfterFunc(d, func() { i.expire(l) })
+	}
+	i.layers[name] = l
+	i.refresh()
+}
+
+// Clear removes the named layer, showing the next highest priority layer
+func (i *Indicator) Clear(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if l, ok := i.layers[name]; ok {
+		if l.expiry != nil {
+			l.expiry.Stop()
+		}
+		delete(i.layers, name)
+		i.refresh()
+	}
+}
+
+// Showing returns the name of the layer being shown, empty if none
+func (i *Indicator) Showing() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.shown == nil {
+		return ""
+	}
+	return i.shown.name
+}
+
+func (i *Indicator) expire(l *layer) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.layers[l.name] == l {
+		delete(i.layers, l.name)
+		i.refresh()
+	}
+}
+
+// refresh shows the top layer, restarting its pattern if it has changed
+func (i *Indicator) refresh() {
+	var top *layer
+	for _, l := range i.layers {
+		if top == nil || l.priority > top.priority || (l.priority == top.priority && l.sequence > top.sequence) {
+			top = l
+		}
+	}
+	if top == i.shown {
+		return
+	}
+	if i.timer != nil {
+		i.timer.Stop()
+		i.timer = nil
+	}
+	i.generation++
+	if i.shown != nil {
+		i.setAll(i.shown.pattern, nil)
+	}
+	i.shown = top
+	if top == nil {
+		i.outputs.Restore()
+		return
+	}
+	i.step(top, 0)
+}
+
+func (i *Indicator) step(l *layer, n int) {
+	current := l.pattern[n]
+	i.setAll(l.pattern, current.Outputs)
+	if len(l.pattern) == 1 {
+		return
+	}
+	generation := i.generation
+	next := (n + 1) % len(l.pattern)
+	i.timer = i.clock.AfterFunc(current.Duration, func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if generation == i.generation {
+			i.step(l, next)
+		}
+	})
+}
+
+// setAll turns on the given outputs and the rest of the pattern's off
+func (i *Indicator) setAll(pattern Pattern, lit []string) {
+	on := map[string]bool{}
+	for _, name := range lit {
+		on[name] = true
+	}
+	for _, s := range pattern {
+		for _, name := range s.Outputs {
+			if !on[name] {
+				i.outputs.Set(name, false)
+			}
+		}
+	}
+	for _, name := range lit {
+		i.outputs.Set(name, true)
+	}
+}
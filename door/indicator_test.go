@@ -0,0 +1,85 @@
+package door
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIndicatorBlink(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	outputs := &fakeOutputs{levels: map[string]bool{}}
+	i := NewIndicator(clock, outputs)
+
+	i.Show(FeedbackLayer, FeedbackPriority, Blink(time.Second, "green"), 0)
+	if !outputs.levels["green"] {
+		t.Errorf("Expected green on\n")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if outputs.levels["green"] {
+		t.Errorf("Expected green off\n")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if !outputs.levels["green"] {
+		t.Errorf("Expected green on again\n")
+	}
+	i.Clear(FeedbackLayer)
+	if outputs.levels["green"] || outputs.restores != 1 || i.Showing() != "" {
+		t.Errorf("Expected green off and outputs restored, got %v\n", outputs)
+	}
+}
+
+func TestIndicatorPriority(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	outputs := &fakeOutputs{levels: map[string]bool{}}
+	i := NewIndicator(clock, outputs)
+
+	i.Show(LockoutLayer, StatusPriority, Solid("red"), 0)
+	i.Show(FeedbackLayer, FeedbackPriority, Solid("blue"), 0)
+	if i.Showing() != FeedbackLayer || outputs.levels["red"] || !outputs.levels["blue"] {
+		t.Errorf("Expected feedback over lockout, got %v, %v\n", i.Showing(), outputs.levels)
+	}
+	i.Show(OfflineLayer, StatusPriority, Solid("white"), 0)
+	if i.Showing() != FeedbackLayer {
+		t.Errorf("Expected lower priority not to be shown, got %v\n", i.Showing())
+	}
+	i.Clear(FeedbackLayer)
+	if i.Showing() != OfflineLayer || !outputs.levels["white"] || outputs.levels["blue"] {
+		t.Errorf("Expected most recent of equal priority, got %v, %v\n", i.Showing(), outputs.levels)
+	}
+	i.Clear(OfflineLayer)
+	if i.Showing() != LockoutLayer || !outputs.levels["red"] {
+		t.Errorf("Expected lockout again, got %v\n", i.Showing())
+	}
+}
+
+func TestIndicatorExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	outputs := &fakeOutputs{levels: map[string]bool{}}
+	i := NewIndicator(clock, outputs)
+
+	i.Show(FaultLayer, AlertPriority, FaultPattern, time.Minute)
+	i.Show(FaultLayer, AlertPriority, FaultPattern, 2*time.Minute)
+	clock.Advance(time.Minute)
+	if i.Showing() != FaultLayer {
+		t.Errorf("Expected replaced layer to keep its new duration\n")
+	}
+	clock.Advance(time.Minute)
+	if i.Showing() != "" || outputs.levels["red"] || outputs.levels["blue"] {
+		t.Errorf("Expected layer to expire, got %v, %v\n", i.Showing(), outputs.levels)
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	pattern, err := ParsePattern("red+siren:250ms, off:1s")
+	if err != nil {
+		t.Errorf("Expected no error, got %v\n", err)
+	}
+	if len(pattern) != 2 || len(pattern[0].Outputs) != 2 || pattern[1].Outputs != nil || pattern[1].Duration != time.Second {
+		t.Errorf("Expected two steps, got %v\n", pattern)
+	}
+	for _, spec := range []string{"", "red", "horn:1s", "latch:1s", "red:0s", "red:soon"} {
+		if _, err := ParsePattern(spec); err == nil {
+			t.Errorf("Expected error for %q\n", spec)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package door
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatchMode is how the latch is actuated to release the door
+type LatchMode string
+
+const (
+	// PulseLatch releases the latch for Pulse, as for a solenoid strike
+	PulseLatch LatchMode = "pulse"
+	// HoldUntilOpen releases the latch until the door opens, or for at most
+	// Hold, as for a maglock
+	HoldUntilOpen LatchMode = "hold_until_open"
+	// HoldLatch releases the latch for Hold, as for a motorised lock
+	HoldLatch LatchMode = "hold"
+	// DoublePulse releases the latch for Pulse, then again after Gap unless
+	// the door has opened, for a strike that sometimes sticks
+	DoublePulse LatchMode = "double_pulse"
+)
+
+// LatchProfile sets how the latch is actuated
+type LatchProfile struct {
+	Mode  LatchMode
+	Pulse time.Duration
+	Hold  time.Duration
+	Gap   time.Duration
+}
+
+// DefaultLatch pulses the solenoid strike of the original build for a second
+var DefaultLatch = LatchProfile{Mode: PulseLatch, Pulse: time.Second}
+
+// ParseLatch reads a latch profile from its mode and durations separated by
+// colons: pulse:1s, hold_until_open:60s, hold:10s or double_pulse:500ms:1s
+// (pulse and gap)
+func ParseLatch(spec string) (LatchProfile, error) {
+	parts := strings.Split(spec, ":")
+	durations := []time.Duration{}
+	for _, part := range parts[1:] {
+		d, err := time.ParseDuration(part)
+		if err != nil || d <= 0 {
+			return LatchProfile{}, fmt.Errorf("DOOR: invalid latch duration: %q", part)
+		}
+		durations = append(durations, d)
+	}
+	profile := LatchProfile{Mode: LatchMode(parts[0])}
+	switch {
+	case profile.Mode == PulseLatch && len(durations) <= 1:
+		profile.Pulse = DefaultLatch.Pulse
+		if len(durations) == 1 {
+			profile.Pulse = durations[0]
+		}
+	case (profile.Mode == HoldUntilOpen || profile.Mode == HoldLatch) && len(durations) == 1:
+		profile.Hold = durations[0]
+	case profile.Mode == DoublePulse && len(durations) == 2:
+		profile.Pulse, profile.Gap = durations[0], durations[1]
+	default:
+		return LatchProfile{}, fmt.Errorf("DOOR: invalid latch profile: %q", spec)
+	}
+	return profile, nil
+}
+
+// Latch actuates the door latch to its profile in the background, so that
+// releasing it never blocks the caller
+type Latch struct {
+	clock    Clock
+	profile  LatchProfile
+	actuator Actuator
+
+	mu         sync.Mutex
+	released   bool
+	timer      Timer
+	generation int
+}
+
+// NewLatch creates an engaged latch
+func NewLatch(clock Clock, profile LatchProfile, actuator Actuator) *Latch {
+	l := &Latch{clock: clock, profile: profile, actuator: actuator}
+	l.set(false)
+	return l
+}
+
+// Release releases the latch, engaging it again as its profile dictates
+func (l *Latch) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancel()
+	l.set(true)
+	switch l.profile.Mode {
+	case PulseLatch:
+		l.after(l.profile.Pulse, l.engage)
+	case HoldUntilOpen, HoldLatch:
+		l.after(l.profile.Hold, l.engage)
+	case DoublePulse:
+		l.after(l.profile.Pulse, func() {
+			l.set(false)
+			l.after(l.profile.Gap, func() {
+				log.Println("DOOR: Door not opened, pulsing latch again")
+				l.set(true)
+				l.after(l.profile.Pulse, l.engage)
+			})
+		})
+	}
+}
+
+// Engage engages the latch at once
+func (l *Latch) Engage() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancel()
+	l.engage()
+}
+
+// Opened engages a latch held until the door opens, and cancels the retry of
+// a double pulse
+func (l *Latch) Opened() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.profile.Mode == HoldUntilOpen || l.profile.Mode == DoublePulse {
+		l.cancel()
+		l.engage()
+	}
+}
+
+// Released reports whether the latch is released
+func (l *Latch) Released() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.released
+}
+
+func (l *Latch) engage() {
+	l.set(false)
+}
+
+func (l *Latch) set(released bool) {
+	if err := l.actuator.Set(released); err != nil {
+		log.Printf("DOOR: Error setting latch: %v\n", err)
+	}
+	l.released = released
+}
+
+// after calls f with the latch locked once d has passed, unless the latch has
+// been released or engaged again since
+func (l *Latch) after(d time.Duration, f func()) {
+	generation := l.generation
+	l.timer = l.clock.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if generation == l.generation {
+			f()
+		}
+	})
+}
+
+func (l *Latch) cancel() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.generation++
+}
@@ -0,0 +1,120 @@
+package door
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatchPulse(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	actuator := &fakeActuator{}
+	l := NewLatch(clock, LatchProfile{Mode: PulseLatch, Pulse: 500 * time.Millisecond}, actuator)
+
+	l.Release()
+	if !actuator.on {
+		t.Errorf("Expected latch released\n")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if actuator.on || l.Released() {
+		t.Errorf("Expected latch engaged after pulse\n")
+	}
+}
+
+func TestLatchHoldUntilOpen(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	actuator := &fakeActuator{}
+	l := NewLatch(clock, LatchProfile{Mode: HoldUntilOpen, Hold: time.Minute}, actuator)
+
+	l.Release()
+	clock.Advance(30 * time.Second)
+	if !actuator.on {
+		t.Errorf("Expected latch held released\n")
+	}
+	l.Opened()
+	if actuator.on {
+		t.Errorf("Expected latch engaged once open\n")
+	}
+
+	l.Release()
+	clock.Advance(time.Minute)
+	if actuator.on {
+		t.Errorf("Expected latch engaged after the longest hold\n")
+	}
+}
+
+func TestLatchHold(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	actuator := &fakeActuator{}
+	l := NewLatch(clock, LatchProfile{Mode: HoldLatch, Hold: 10 * time.Second}, actuator)
+
+	l.Release()
+	l.Opened()
+	clock.Advance(5 * time.Second)
+	if !actuator.on {
+		t.Errorf("Expected latch held released after opening\n")
+	}
+	clock.Advance(5 * time.Second)
+	if actuator.on {
+		t.Errorf("Expected latch engaged after hold\n")
+	}
+}
+
+func TestLatchDoublePulse(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	actuator := &fakeActuator{}
+	l := NewLatch(clock, LatchProfile{Mode: DoublePulse, Pulse: 500 * time.Millisecond, Gap: time.Second}, actuator)
+
+	l.Release()
+	clock.Advance(500 * time.Millisecond)
+	clock.Advance(time.Second)
+	if !actuator.on {
+		t.Errorf("Expected second pulse\n")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if actuator.on || actuator.changes != 4 {
+		t.Errorf("Expected two pulses, got %v changes\n", actuator.changes)
+	}
+
+	l.Release()
+	clock.Advance(500 * time.Millisecond)
+	l.Opened()
+	clock.Advance(2 * time.Second)
+	if actuator.changes != 6 {
+		t.Errorf("Expected no retry once open, got %v changes\n", actuator.changes)
+	}
+}
+
+func TestLatchEngageCancels(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	actuator := &fakeActuator{}
+	l := NewLatch(clock, LatchProfile{Mode: HoldLatch, Hold: 10 * time.Second}, actuator)
+
+	l.Release()
+	l.Engage()
+	l.Release()
+	clock.Advance(10 * time.Second)
+	if actuator.on {
+		t.Errorf("Expected latch engaged\n")
+	}
+}
+
+func TestParseLatch(t *testing.T) {
+	valid := map[string]LatchProfile{
+		"pulse":                 DefaultLatch,
+		"pulse:250ms":           {Mode: PulseLatch, Pulse: 250 * time.Millisecond},
+		"hold_until_open:1m":    {Mode: HoldUntilOpen, Hold: time.Minute},
+		"hold:10s":              {Mode: HoldLatch, Hold: 10 * time.Second},
+		"double_pulse:500ms:1s": {Mode: DoublePulse, Pulse: 500 * time.Millisecond, Gap: time.Second},
+	}
+	for spec, expected := range valid {
+		profile, err := ParseLatch(spec)
+		if err != nil || profile != expected {
+			t.Errorf("Expected %v for %q, got %v, %v\n", expected, spec, profile, err)
+		}
+	}
+	for _, spec := range []string{"", "hold", "hold_until_open", "double_pulse:1s", "pulse:-1s", "magic:1s"} {
+		if _, err := ParseLatch(spec); err == nil {
+			t.Errorf("Expected error for %q\n", spec)
+		}
+	}
+}
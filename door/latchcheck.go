@@ -0,0 +1,190 @@
+package door
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// LatchCheck sets how a latch that fails to release is detected and retried
+type LatchCheck struct {
+	// Settle is how long after a release the latch sense input is read
+	Settle time.Duration
+	// Retry is how long to wait for the door to open before releasing the
+	// latch again, zero disabling retries
+	Retry   time.Duration
+	Retries int
+	// FaultAfter is the number of unlocks in a row in which the latch fails
+	// that raise a fault
+	FaultAfter int
+}
+
+// DefaultLatchCheck retries once after five seconds, and raises a fault after
+// three failed unlocks
+var DefaultLatchCheck = LatchCheck{
+	Settle:     300 * time.Millisecond,
+	Retry:      5 * time.Second,
+	Retries:    1,
+	FaultAfter: 3,
+}
+
+// Releaser releases the latch
+type Releaser interface {
+	Release()
+}
+
+// LatchMonitor tells a latch that failed from a door that was not pulled.
+// With a latch sense input the latch is seen to release; without one, a
+// door that is not opened after every retry counts against the latch. Keys
+// pressed while the door is unlocked show someone is still waiting, and
+// retry at once.
+type LatchMonitor struct {
+	clock   Clock
+	config  LatchCheck
+	latch   Releaser
+	sense   Sensor
+	faultFn func(failures int, detail string)
+
+	mu         sync.Mutex
+	unlocked   bool
+	retries    int
+	released   bool
+	failures   int
+	timers     []Timer
+	generation int
+}
+
+// NewLatchMonitor creates a monitor of the latch, sense being nil if no latch
+// sense input is fitted. Add its Observe method to the door controller.
+func NewLatchMonitor(clock Clock, config LatchCheck, latch Releaser, sense Sensor, faultFn func(failures int, detail string)) *LatchMonitor {
+	return &LatchMonitor{
+		clock:   clock,
+		config:  config,
+		latch:   latch,
+		sense:   sense,
+		faultFn: faultFn,
+	}
+}
+
+// Observe follows the door controller through each unlock
+func (m *LatchMonitor) Observe(t Transition) {
+	m.mu.Lock()
+	var fault string
+	switch {
+	case t.To == Unlocked && t.From != Opened && t.From != HeldOpen:
+		m.stop()
+		m.unlocked = true
+		m.retries = 0
+		m.released = false
+		m.schedule()
+	case t.To == Unlocked:
+		// unlocked again during a hold, the latch having worked
+	case t.To == Opened:
+		m.stop()
+		m.unlocked = false
+		m.failures = 0
+	case t.From == Unlocked && t.Event == TimedOut:
+		m.stop()
+		m.unlocked = false
+		fault = m.unopened()
+	case t.From == Unlocked:
+		m.stop()
+		m.unlocked = false
+	}
+	failures := m.failures
+	if fault != "" {
+		m.failures = 0
+	}
+	m.mu.Unlock()
+
+	if fault != "" {
+		log.Printf("DOOR: Latch fault after %v failures: %v\n", failures, fault)
+		m.faultFn(failures, fault)
+	}
+}
+
+// KeypadActivity retries the latch at once if the door is unlocked, someone
+// evidently still waiting at a door that has not opened
+func (m *LatchMonitor) KeypadActivity() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.unlocked {
+		m.retry()
+	}
+}
+
+// unopened counts an unlock in which the door was never opened against the
+// latch, unless it was seen to release, returning a fault if there have been
+// too many
+func (m *LatchMonitor) unopened() string {
+	if m.released {
+		log.Println("DOOR: Latch released but door not opened")
+		m.failures = 0
+		return ""
+	}
+	m.failures++
+	if m.failures < m.config.FaultAfter {
+		return ""
+	}
+	if m.sense != nil {
+		return fmt.Sprintf("latch not released in %v unlocks", m.failures)
+	}
+	return fmt.Sprintf("door not opened in %v unlocks", m.failures)
+}
+
+// schedule reads the latch sense once it has settled, and retries if the door
+// has not opened in time
+func (m *LatchMonitor) schedule() {
+	generation := m.generation
+	if m.sense != nil {
+		m.timers = append(m.timers, m.clock.AfterFunc(m.config.Settle, func() { m.checkSense(generation) }))
+	}
+	if m.config.Retry > 0 {
+		m.timers = append(m.timers, m.clock.AfterFunc(m.config.Retry, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if generation == m.generation {
+				m.retry()
+			}
+		}))
+	}
+}
+
+func (m *LatchMonitor) checkSense(generation int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if generation != m.generation || m.released {
+		return
+	}
+	released, err := m.sense.Active()
+	if err != nil {
+		log.Printf("DOOR: Error reading latch sense: %v\n", err)
+		return
+	}
+	if released {
+		m.released = true
+		return
+	}
+	log.Println("DOOR: Latch did not release")
+	m.retry()
+}
+
+func (m *LatchMonitor) retry() {
+	if m.released || m.retries >= m.config.Retries {
+		return
+	}
+	m.retries++
+	log.Printf("DOOR: Retrying latch, attempt %v\n", m.retries)
+	m.stop()
+	m.latch.Release()
+	m.schedule()
+}
+
+func (m *LatchMonitor) stop() {
+	for _, t := range m.timers {
+		t.Stop()
+	}
+	m.timers = nil
+	m.generation++
+}
@@ -0,0 +1,116 @@
+package door
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeReleaser struct {
+	releases int
+}
+
+func (r *fakeReleaser) Release() { r.releases++ }
+
+var latchCheck = LatchCheck{
+	Settle:     300 * time.Millisecond,
+	Retry:      5 * time.Second,
+	Retries:    1,
+	FaultAfter: 2,
+}
+
+func unlock(m *LatchMonitor) {
+	m.Observe(Transition{From: Verifying, To: Unlocked, Event: CodeGranted})
+}
+
+func neverOpened(m *LatchMonitor) {
+	m.Observe(Transition{From: Unlocked, To: Idle, Event: TimedOut})
+}
+
+func TestLatchRetryWithoutSense(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	latch := &fakeReleaser{}
+	faults := []string{}
+	m := NewLatchMonitor(clock, latchCheck, latch, nil, func(failures int, detail string) { faults = append(faults, detail) })
+
+	unlock(m)
+	clock.Advance(5 * time.Second)
+	clock.Advance(5 * time.Second)
+	if latch.releases != 1 {
+		t.Errorf("Expected a single retry, got %v\n", latch.releases)
+	}
+	neverOpened(m)
+	if len(faults) != 0 {
+		t.Errorf("Expected no fault after one failure\n")
+	}
+	unlock(m)
+	neverOpened(m)
+	if len(faults) != 1 {
+		t.Errorf("Expected a fault after two failures, got %v\n", faults)
+	}
+}
+
+func TestLatchOpenedResets(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	latch := &fakeReleaser{}
+	faults := []string{}
+	m := NewLatchMonitor(clock, latchCheck, latch, nil, func(failures int, detail string) { faults = append(faults, detail) })
+
+	unlock(m)
+	neverOpened(m)
+	unlock(m)
+	m.Observe(Transition{From: Unlocked, To: Opened, Event: ContactOpened})
+	clock.Advance(10 * time.Second)
+	unlock(m)
+	neverOpened(m)
+	if len(faults) != 0 || latch.releases != 0 {
+		t.Errorf("Expected opening to reset failures and cancel retries, got %v, %v\n", faults, latch.releases)
+	}
+}
+
+func TestLatchKeypadActivity(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	latch := &fakeReleaser{}
+	faults := []string{}
+	m := NewLatchMonitor(clock, latchCheck, latch, nil, func(failures int, detail string) { faults = append(faults, detail) })
+
+	m.KeypadActivity()
+	if latch.releases != 0 {
+		t.Errorf("Expected no retry while locked\n")
+	}
+	unlock(m)
+	m.KeypadActivity()
+	m.KeypadActivity()
+	if latch.releases != 1 {
+		t.Errorf("Expected a single retry on keypad activity, got %v\n", latch.releases)
+	}
+}
+
+func TestLatchSense(t *testing.T) {
+	sense := &fakeSensor{}
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	latch := &fakeReleaser{}
+	faults := []string{}
+	m := NewLatchMonitor(clock, latchCheck, latch, sense, func(failures int, detail string) { faults = append(faults, detail) })
+
+	unlock(m)
+	clock.Advance(300 * time.Millisecond)
+	if latch.releases != 1 {
+		t.Errorf("Expected a retry when the latch is not sensed, got %v\n", latch.releases)
+	}
+	neverOpened(m)
+	unlock(m)
+	neverOpened(m)
+	if len(faults) != 1 || faults[0] != "latch not released in 2 unlocks" {
+		t.Errorf("Expected a latch fault, got %v\n", faults)
+	}
+
+	sense.active = true
+	for i := 0; i < 3; i++ {
+		unlock(m)
+		clock.Advance(300 * time.Millisecond)
+		neverOpened(m)
+	}
+	if len(faults) != 1 {
+		t.Errorf("Expected a released latch not to count as a failure, got %v\n", faults)
+	}
+}
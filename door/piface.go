@@ -0,0 +1,60 @@
+package door
+
+import (
+	"fmt"
+
+	"github.com/luismesas/goPi/MCP23S17"
+	"github.com/luismesas/goPi/piface"
+	"github.com/luismesas/goPi/spi"
+)
+
+type pifaceDriver struct {
+	pfd *piface.PiFaceDigital
+}
+
+type pifaceLine struct {
+	bit *MCP23S17.MCP23S17RegisterBit
+}
+
+func openPiFace() (Driver, error) {
+	pfd := piface.NewPiFaceDigital(spi.DEFAULT_HARDWARE_ADDR, spi.DEFAULT_BUS, spi.DEFAULT_CHIP)
+	err := pfd.InitBoard()
+	if err != nil {
+		fmt.Printf("DOOR: Error on init board: %s", err)
+		return nil, err
+	}
+	return &pifaceDriver{pfd: pfd}, nil
+}
+
+func (d *pifaceDriver) Output(pin Pin) (Actuator, error) {
+	if pin.Line < 0 || pin.Line >= len(d.pfd.Leds) {
+		return nil, fmt.Errorf("DOOR: no PiFace output %v", pin.Line)
+	}
+	line := pifaceLine{d.pfd.Leds[pin.Line]}
+	line.Set(pin.ActiveLow)
+	return line, nil
+}
+
+func (d *pifaceDriver) Input(pin Pin) (Sensor, error) {
+	if pin.Line < 0 || pin.Line >= len(d.pfd.Switches) {
+		return nil, fmt.Errorf("DOOR: no PiFace input %v", pin.Line)
+	}
+	return pifaceLine{d.pfd.Switches[pin.Line]}, nil
+}
+
+func (d *pifaceDriver) Close() error {
+	return d.pfd.Close()
+}
+
+func (l pifaceLine) Set(on bool) error {
+	if on {
+		l.bit.AllOn()
+	} else {
+		l.bit.AllOff()
+	}
+	return nil
+}
+
+func (l pifaceLine) Active() (bool, error) {
+	return l.bit.Value() != 0, nil
+}
@@ -0,0 +1,114 @@
+package door
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Simulator is a driver with no hardware, its outputs being logged and its
+// inputs set by hand or by Control. As on the PiFace, outputs and inputs are
+// numbered separately.
+type Simulator struct {
+	mu      sync.Mutex
+	outputs map[int]bool
+	inputs  map[int]bool
+}
+
+type simOutput struct {
+	sim  *Simulator
+	line int
+}
+
+type simInput struct {
+	sim  *Simulator
+	line int
+}
+
+// NewSimulator creates a simulated board. Each line starts at the inactive
+// level of the pin it is opened for, unless an input has already been set.
+func NewSimulator() *Simulator {
+	return &Simulator{outputs: map[int]bool{}, inputs: map[int]bool{}}
+}
+
+func (s *Simulator) Output(pin Pin) (Actuator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs[pin.Line] = pin.ActiveLow
+	return simOutput{s, pin.Line}, nil
+}
+
+func (s *Simulator) Input(pin Pin) (Sensor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, set := s.inputs[pin.Line]; !set {
+		s.inputs[pin.Line] = pin.ActiveLow
+	}
+	return simInput{s, pin.Line}, nil
+}
+
+// Control reads commands setting the inputs by name, one per line, such as
+// "contact active" to open the door or "override inactive", until the reader
+// ends. Active and inactive follow the pin's polarity.
+func (s *Simulator) Control(r io.Reader, pins Pins) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		pin, ok := pins[fields[0]]
+		if len(fields) != 2 || !ok || !isInput(fields[0]) || pin.Line < 0 || (fields[1] != "active" && fields[1] != "inactive") {
+			log.Printf("DOOR: Invalid simulator command: %q\n", scanner.Text())
+			continue
+		}
+		log.Printf("DOOR: Simulated input %v: %v\n", fields[0], fields[1])
+		s.SetInput(pin.Line, (fields[1] == "active") != pin.ActiveLow)
+	}
+	return scanner.Err()
+}
+
+func (s *Simulator) Close() error {
+	return nil
+}
+
+// SetInput drives a simulated input high or low
+func (s *Simulator) SetInput(line int, high bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputs[line] = high
+}
+
+// OutputLevel reads a simulated output
+func (s *Simulator) OutputLevel(line int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outputs[line]
+}
+
+func isInput(name string) bool {
+	for _, i := range inputs {
+		if string(i) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o simOutput) Set(on bool) error {
+	o.sim.mu.Lock()
+	defer o.sim.mu.Unlock()
+	if o.sim.outputs[o.line] != on {
+		log.Printf("DOOR: Simulated output %v: %v\n", o.line, on)
+	}
+	o.sim.outputs[o.line] = on
+	return nil
+}
+
+func (i simInput) Active() (bool, error) {
+	i.sim.mu.Lock()
+	defer i.sim.mu.Unlock()
+	return i.sim.inputs[i.line], nil
+}
@@ -1,29 +1,137 @@
 package door
 
 import (
+	"fmt"
 	"log"
-	"strconv"
+	"math"
+	"sync"
 	"time"
+)
+
+// Twilight is how far below the horizon the sun must be before it is dark
+type Twilight string
 
-	"github.com/cpucycle/astrotime"
+const (
+	Official Twilight = "official"
+	Civil    Twilight = "civil"
+	Nautical Twilight = "nautical"
 )
 
-var lastState bool
+// zeniths of the sun's centre, in degrees, at each twilight. Official allows for
+// refraction and the sun's radius.
+var zeniths = map[Twilight]float64{
+	Official: 90.833,
+	Civil:    96,
+	Nautical: 102,
+}
+
+// Polar is set on a day the sun neither rises nor sets
+type Polar string
+
+const (
+	PolarDay   Polar = "polar_day"
+	PolarNight Polar = "polar_night"
+)
 
-func CheckSunRise(latitude, longitude string, dayStart, dayEnd time.Duration, change func(bool, bool)) {
-	lat, err := strconv.ParseFloat(latitude, 64)
-	if err != nil {
-		log.Fatalf("Invalid latitude: %v\n", latitude)
+// Sky configures when it is dark outside, and the hours in which the lights are
+// turned on when it is
+type Sky struct {
+	Latitude  float64
+	Longitude float64
+	// Location is the timezone of the calendar day, and of DayStart and DayEnd
+	Location *time.Location
+	Twilight Twilight
+	// SunriseOffset and SunsetOffset move the sun's events, e.g. a SunsetOffset
+	// of -15m is dark 15 minutes before sunset
+	SunriseOffset time.Duration
+	SunsetOffset  time.Duration
+	// DayStart and DayEnd are clock times, e.g. 6h30m for 06:30
+	DayStart time.Duration
+	DayEnd   time.Duration
+}
+
+// ParseTwilight checks the twilight, defaulting to Official
+func ParseTwilight(s string) (Twilight, error) {
+	if s == "" {
+		return Official, nil
 	}
-	long, err := strconv.ParseFloat(longitude, 64)
-	if err != nil {
-		log.Fatalf("Invalid longitude: %v\n", longitude)
+	if _, ok := zeniths[Twilight(s)]; !ok {
+		return "", fmt.Errorf("unknown twilight %q", s)
 	}
+	return Twilight(s), nil
+}
 
+// SunDay is the sun's events on one local calendar day
+type SunDay struct {
+	Date    string
+	Sunrise time.Time
+	Sunset  time.Time
+	// Polar is set, and Sunrise and Sunset are zero, if the sun does not cross
+	// the twilight's zenith
+	Polar Polar
+	Start time.Time
+	End   time.Time
+}
+
+// Dark reports whether it is dark at the instant
+func (d SunDay) Dark(now time.Time) bool {
+	switch d.Polar {
+	case PolarDay:
+		return false
+	case PolarNight:
+		return true
+	}
+	return now.Before(d.Sunrise) || !now.Before(d.Sunset)
+}
+
+// Sun works out whether it is dark, calculating the sun's events once a day
+type Sun struct {
+	sky Sky
+	mu  sync.Mutex
+	day SunDay
+}
+
+// NewSun creates a Sun for the sky
+func NewSun(sky Sky) *Sun {
+	if sky.Location == nil {
+		sky.Location = time.Local
+	}
+	if sky.Twilight == "" {
+		sky.Twilight = Official
+	}
+	return &Sun{sky: sky}
+}
+
+// Day returns the sun's events on the local calendar day of the instant
+func (s *Sun) Day(now time.Time) SunDay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	local := now.In(s.sky.Location)
+	if date := local.Format("2006-01-02"); date != s.day.Date {
+		s.day = s.sky.calculate(local.Year(), local.Month(), local.Day())
+		log.Printf("SUN: %v sunrise %v, sunset %v %v\n", s.day.Date, s.day.Sunrise, s.day.Sunset, s.day.Polar)
+	}
+	return s.day
+}
+
+// Dark reports whether it is dark in the day's hours, when the lights should be
+// on, and whether it is dark at all
+func (s *Sun) Dark(now time.Time) (bool, bool) {
+	day := s.Day(now)
+	dark := day.Dark(now)
+	return dark && now.After(day.Start) && now.Before(day.End), dark
+}
+
+var lastState bool
+
+// CheckSunRise calls change every minute with whether it is dark in the day's
+// hours, and whether it is dark at all
+func CheckSunRise(sky Sky, change func(bool, bool)) {
+	sun := NewSun(sky)
 	go func() {
 		change(false, false)
 		for {
-			nextState, night := adjust(time.Now(), lat, long, dayStart, dayEnd)
+			nextState, night := sun.Dark(time.Now())
 			if nextState != lastState {
 				log.Printf("SUN: Changed light state to %v\n", nextState)
 			}
@@ -34,21 +142,110 @@ func CheckSunRise(latitude, longitude string, dayStart, dayEnd time.Duration, ch
 	}()
 }
 
-func adjust(now time.Time, latitude, longitude float64, dayStart, dayEnd time.Duration) (bool, bool) {
-	nowDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	start := nowDay.Add(dayStart)
-	end := nowDay.Add(dayEnd)
-	sunrise := astrotime.CalcSunrise(nowDay, latitude, longitude)
-	sunset := astrotime.CalcSunset(nowDay, latitude, longitude)
+func (sky Sky) calculate(year int, month time.Month, day int) SunDay {
+	d := SunDay{
+		Date:  time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02"),
+		Start: clockTime(year, month, day, sky.DayStart, sky.Location),
+		End:   clockTime(year, month, day, sky.DayEnd, sky.Location),
+	}
+	rise, set, polar := sunEvents(year, month, day, sky.Latitude, sky.Longitude, zeniths[sky.Twilight])
+	if polar != "" {
+		d.Polar = polar
+		return d
+	}
+	d.Sunrise = rise.Add(sky.SunriseOffset).In(sky.Location)
+	d.Sunset = set.Add(sky.SunsetOffset).In(sky.Location)
+	return d
+}
+
+// clockTime is the wall clock time on the day, so that 06:30 is 06:30 on the
+// days the clocks change
+func clockTime(year int, month time.Month, day int, clock time.Duration, loc *time.Location) time.Time {
+	hours := int(clock / time.Hour)
+	minutes := int(clock % time.Hour / time.Minute)
+	seconds := int(clock % time.Minute / time.Second)
+	return time.Date(year, month, day, hours, minutes, seconds, 0, loc)
+}
+
+// sunEvents calculates when the sun's centre crosses the zenith, around solar
+// noon on the calendar day, with NOAA's solar equations. Longitude is east
+// positive.
+func sunEvents(year int, month time.Month, day int, latitude, longitude, zenith float64) (time.Time, time.Time, Polar) {
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	jd := julianDay(midnight)
 
-	// fmt.Printf("Sunrise %v\n", sunrise)
-	// fmt.Printf("Sunset  %v\n", sunset)
-	// fmt.Printf("Now     %v\n", now)
+	noon := 720 - 4*longitude
+	_, eqTime := solarPosition(jd + noon/1440)
+	noon = 720 - 4*longitude - eqTime
 
-	if now.After(start) && now.Before(end) {
-		if now.Before(sunrise) || now.After(sunset) {
-			return true, true
+	var times [2]float64
+	for i, sign := range []float64{1, -1} {
+		at := noon
+		// Iterating on the time of the event corrects for the sun moving
+		for pass := 0; pass < 3; pass++ {
+			declination, eqTime := solarPosition(jd + at/1440)
+			hourAngle, polar := hourAngle(latitude, declination, zenith)
+			if polar != "" {
+				return time.Time{}, time.Time{}, polar
+			}
+			at = 720 - 4*(longitude+sign*hourAngle) - eqTime
 		}
+		times[i] = at
+	}
+	minutes := func(m float64) time.Duration {
+		return time.Duration(math.Round(m * float64(time.Minute)))
+	}
+	return midnight.Add(minutes(times[0])), midnight.Add(minutes(times[1])), ""
+}
+
+// hourAngle of the sun at the zenith, in degrees, or the polar condition if it
+// does not reach it
+func hourAngle(latitude, declination, zenith float64) (float64, Polar) {
+	lat := radians(latitude)
+	dec := radians(declination)
+	cos := (math.Cos(radians(zenith)) - math.Sin(lat)*math.Sin(dec)) / (math.Cos(lat) * math.Cos(dec))
+	if cos > 1 {
+		return 0, PolarNight
+	}
+	if cos < -1 {
+		return 0, PolarDay
 	}
-	return false, now.Before(sunrise) || now.After(sunset)
+	return degrees(math.Acos(cos)), ""
+}
+
+// solarPosition returns the sun's declination in degrees, and the equation of
+// time in minutes, at the Julian day
+func solarPosition(jd float64) (float64, float64) {
+	t := (jd - 2451545) / 36525
+	meanLong := math.Mod(280.46646+t*(36000.76983+t*0.0003032), 360)
+	anomaly := 357.52911 + t*(35999.05029-0.0001537*t)
+	eccentricity := 0.016708634 - t*(0.000042037+0.0000001267*t)
+	centre := math.Sin(radians(anomaly))*(1.914602-t*(0.004817+0.000014*t)) +
+		math.Sin(radians(2*anomaly))*(0.019993-0.000101*t) +
+		math.Sin(radians(3*anomaly))*0.000289
+	omega := 125.04 - 1934.136*t
+	apparentLong := meanLong + centre - 0.00569 - 0.00478*math.Sin(radians(omega))
+	meanObliquity := 23 + (26+(21.448-t*(46.815+t*(0.00059-t*0.001813)))/60)/60
+	obliquity := meanObliquity + 0.00256*math.Cos(radians(omega))
+	declination := degrees(math.Asin(math.Sin(radians(obliquity)) * math.Sin(radians(apparentLong))))
+
+	y := math.Pow(math.Tan(radians(obliquity)/2), 2)
+	l0 := radians(meanLong)
+	m := radians(anomaly)
+	eqTime := y*math.Sin(2*l0) - 2*eccentricity*math.Sin(m) +
+		4*eccentricity*y*math.Sin(m)*math.Cos(2*l0) -
+		0.5*y*y*math.Sin(4*l0) - 1.25*eccentricity*eccentricity*math.Sin(2*m)
+	return declination, 4 * degrees(eqTime)
+}
+
+func julianDay(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+func radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func degrees(r float64) float64 {
+	return r * 180 / math.Pi
 }
@@ -1,140 +1,211 @@
 package door
 
 import (
-	"strconv"
 	"testing"
 	"time"
 )
 
+func barnet(start, end time.Duration) *Sun {
+	return NewSun(Sky{Latitude: 51.613078, Longitude: -0.165323, Location: time.UTC, DayStart: start, DayEnd: end})
+}
+
 func TestWinter(t *testing.T) {
-	lat, _ := strconv.ParseFloat("51.613078", 64)
-	long, _ := strconv.ParseFloat("-0.165323", 64)
 	start, _ := time.ParseDuration("6h30m")
 	end, _ := time.ParseDuration("22h")
+	sun := barnet(start, end)
 
-	// Sunrise: 2017-11-13 07:14:28 +0000 UTC
-	// Sunset: 2017-11-13 16:12:10 +0000 UTC
+	// Sunrise: 2017-11-13 07:15:47 +0000 UTC
+	// Sunset: 2017-11-13 16:13:29 +0000 UTC
 
 	now, _ := time.Parse(time.RFC3339, "2017-11-13T06:29:59Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-11-13T06:30:01Z")
-	if s, _ := adjust(now, lat, long, start, end); !s {
+	if s, _ := sun.Dark(now); !s {
 		t.Errorf("Expected true\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-11-13T07:14:27Z")
-	if s, _ := adjust(now, lat, long, start, end); !s {
+	now, _ = time.Parse(time.RFC3339, "2017-11-13T07:15:46Z")
+	if s, _ := sun.Dark(now); !s {
 		t.Errorf("Expected true\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-11-13T07:14:29Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	now, _ = time.Parse(time.RFC3339, "2017-11-13T07:15:48Z")
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-11-13T12:00:00Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-11-13T16:12:09Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	now, _ = time.Parse(time.RFC3339, "2017-11-13T16:13:28Z")
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-11-13T16:12:11Z")
-	if s, _ := adjust(now, lat, long, start, end); !s {
+	now, _ = time.Parse(time.RFC3339, "2017-11-13T16:13:30Z")
+	if s, _ := sun.Dark(now); !s {
 		t.Errorf("Expected true\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-11-13T21:59:00Z")
-	if s, _ := adjust(now, lat, long, start, end); !s {
+	if s, _ := sun.Dark(now); !s {
 		t.Errorf("Expected true\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-11-13T22:01:00Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-11-13T23:59:59Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 }
 
 func TestSummer(t *testing.T) {
-	lat, _ := strconv.ParseFloat("51.613078", 64)
-	long, _ := strconv.ParseFloat("-0.165323", 64)
 	start, _ := time.ParseDuration("6h30m")
 	end, _ := time.ParseDuration("22h")
+	sun := barnet(start, end)
 
-	// Sunrise: 2017-06-21 03:41:21 +0000 UTC
-	// Sunset: 2017-06-21 20:20:59 +0000 UTC
+	// Sunrise: 2017-06-21 03:42:41 +0000 UTC
+	// Sunset: 2017-06-21 20:22:18 +0000 UTC
 
-	now, _ := time.Parse(time.RFC3339, "2017-06-21T03:41:20Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	now, _ := time.Parse(time.RFC3339, "2017-06-21T03:42:40Z")
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-06-21T03:41:22Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	now, _ = time.Parse(time.RFC3339, "2017-06-21T03:42:42Z")
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-06-21T06:29:59Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-06-21T06:30:01Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-06-21T12:00:00Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-06-21T20:20:58Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	now, _ = time.Parse(time.RFC3339, "2017-06-21T20:22:17Z")
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
-	now, _ = time.Parse(time.RFC3339, "2017-06-21T20:21:00Z")
-	if s, _ := adjust(now, lat, long, start, end); !s {
+	now, _ = time.Parse(time.RFC3339, "2017-06-21T20:22:19Z")
+	if s, _ := sun.Dark(now); !s {
 		t.Errorf("Expected true\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-06-21T21:59:00Z")
-	if s, _ := adjust(now, lat, long, start, end); !s {
+	if s, _ := sun.Dark(now); !s {
 		t.Errorf("Expected true\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-06-21T22:01:00Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 	now, _ = time.Parse(time.RFC3339, "2017-06-21T23:59:59Z")
-	if s, _ := adjust(now, lat, long, start, end); s {
+	if s, _ := sun.Dark(now); s {
 		t.Errorf("Expected false\n")
 	}
 }
 
-// There appears to be an issue when calculating sunrise/set. The calculation is sensitive
-// to the time component of the time instant passed into `CalcSunrise` and `CalcSunset`;
-// if for a given day a pass I in multiple instants, each with a slightly different time
-// component, the calculates for sunrise/set for that day will vary per instant. Here is
-// an example tests case.
-
-// func TestDrift(t *testing.T) {
-// 	lat, _ := strconv.ParseFloat("56.613078", 64)
-// 	long, _ := strconv.ParseFloat("-2.165323", 64)
-
-// 	now1, _ := time.Parse(time.RFC3339, "2017-11-13T01:00:00Z")
-// 	sunrise1 := astrotime.CalcSunrise(now1, lat, long)
-// 	sunset1 := astrotime.CalcSunset(now1, lat, long)
-
-// 	now2, _ := time.Parse(time.RFC3339, "2017-11-13T02:00:00Z")
-// 	sunrise2 := astrotime.CalcSunrise(now2, lat, long)
-// 	sunset2 := astrotime.CalcSunset(now2, lat, long)
-
-// 	if !sunrise1.Equal(sunrise2) {
-// 		// fails
-// 		t.Errorf("Expected sunrise instants to match but %v != %v\n", sunrise1, sunrise2)
-// 	}
-// 	if !sunset1.Equal(sunset2) {
-// 		//fails
-// 		t.Errorf("Expected sunset instants to match but %v != %v\n", sunset1, sunset2)
-// 	}
-// }
-
-// To work around this I'm simply setting the time component of the input instant to
-// `00:00:00+0ns`, however I believe this should be the responsibility of `astrotime`.
+func TestDrift(t *testing.T) {
+	sun := barnet(0, 24*time.Hour)
+
+	now1, _ := time.Parse(time.RFC3339, "2017-11-13T01:00:00Z")
+	day1 := sun.Day(now1)
+	now2, _ := time.Parse(time.RFC3339, "2017-11-13T23:00:00Z")
+	day2 := NewSun(sun.sky).Day(now2)
+
+	if !day1.Sunrise.Equal(day2.Sunrise) {
+		t.Errorf("Expected sunrise instants to match but %v != %v\n", day1.Sunrise, day2.Sunrise)
+	}
+	if !day1.Sunset.Equal(day2.Sunset) {
+		t.Errorf("Expected sunset instants to match but %v != %v\n", day1.Sunset, day2.Sunset)
+	}
+}
+
+func TestDaylightSaving(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("No timezone data: %v\n", err)
+	}
+	sun := NewSun(Sky{Latitude: 51.613078, Longitude: -0.165323, Location: london, DayStart: 6*time.Hour + 30*time.Minute, DayEnd: 22 * time.Hour})
+
+	// The clocks go forward at 01:00 UTC, so the day starts at 05:30 UTC
+	now, _ := time.Parse(time.RFC3339, "2017-03-26T12:00:00Z")
+	day := sun.Day(now)
+	if day.Start.Hour() != 6 || day.Start.Minute() != 30 || day.Start.UTC().Hour() != 5 {
+		t.Errorf("Expected day to start at 06:30 BST but %v\n", day.Start)
+	}
+	if day.End.UTC().Hour() != 21 {
+		t.Errorf("Expected day to end at 21:00 UTC but %v\n", day.End.UTC())
+	}
+	// Sunrise is 05:45 UTC, 06:45 BST
+	now, _ = time.Parse(time.RFC3339, "2017-03-26T05:35:00Z")
+	if s, _ := sun.Dark(now); !s {
+		t.Errorf("Expected true\n")
+	}
+
+	// Half past midnight BST on the 26th is still the 25th in UTC
+	now, _ = time.Parse(time.RFC3339, "2017-08-25T23:30:00Z")
+	if day := sun.Day(now); day.Date != "2017-08-26" {
+		t.Errorf("Expected local date 2017-08-26 but %v\n", day.Date)
+	}
+}
+
+func TestTwilight(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2017-11-13T16:30:00Z")
+	official := barnet(0, 24*time.Hour)
+	civil := NewSun(Sky{Latitude: 51.613078, Longitude: -0.165323, Location: time.UTC, Twilight: Civil, DayEnd: 24 * time.Hour})
+	nautical := NewSun(Sky{Latitude: 51.613078, Longitude: -0.165323, Location: time.UTC, Twilight: Nautical, DayEnd: 24 * time.Hour})
+
+	if _, dark := official.Dark(now); !dark {
+		t.Errorf("Expected dark after sunset\n")
+	}
+	if _, dark := civil.Dark(now); dark {
+		t.Errorf("Expected light in civil twilight\n")
+	}
+	if !civil.Day(now).Sunset.Before(nautical.Day(now).Sunset) {
+		t.Errorf("Expected nautical dusk after civil dusk\n")
+	}
+	if !nautical.Day(now).Sunrise.Before(civil.Day(now).Sunrise) {
+		t.Errorf("Expected nautical dawn before civil dawn\n")
+	}
+	if _, err := ParseTwilight("astronomical"); err == nil {
+		t.Errorf("Expected error for unknown twilight\n")
+	}
+}
+
+func TestOffset(t *testing.T) {
+	sun := NewSun(Sky{Latitude: 51.613078, Longitude: -0.165323, Location: time.UTC, DayEnd: 24 * time.Hour,
+		SunriseOffset: 15 * time.Minute, SunsetOffset: -15 * time.Minute})
+
+	now, _ := time.Parse(time.RFC3339, "2017-11-13T16:00:00Z")
+	if _, dark := sun.Dark(now); !dark {
+		t.Errorf("Expected dark 15 minutes before sunset\n")
+	}
+	now, _ = time.Parse(time.RFC3339, "2017-11-13T07:25:00Z")
+	if _, dark := sun.Dark(now); !dark {
+		t.Errorf("Expected dark until 15 minutes after sunrise\n")
+	}
+}
+
+func TestPolar(t *testing.T) {
+	sun := NewSun(Sky{Latitude: 78.22, Longitude: 15.65, Location: time.UTC, DayEnd: 24 * time.Hour})
+
+	now, _ := time.Parse(time.RFC3339, "2017-06-21T00:00:01Z")
+	if day := sun.Day(now); day.Polar != PolarDay {
+		t.Errorf("Expected polar day but %v\n", day.Polar)
+	}
+	if s, dark := sun.Dark(now); s || dark {
+		t.Errorf("Expected light at midnight in polar day\n")
+	}
+	now, _ = time.Parse(time.RFC3339, "2017-12-21T12:00:00Z")
+	if day := sun.Day(now); day.Polar != PolarNight {
+		t.Errorf("Expected polar night but %v\n", day.Polar)
+	}
+	if s, dark := sun.Dark(now); !s || !dark {
+		t.Errorf("Expected dark at noon in polar night\n")
+	}
+}
@@ -0,0 +1,119 @@
+package door
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const tamperLayer = "tamper"
+
+// TamperConfig sets when a forced door raises the alarm, and how
+type TamperConfig struct {
+	// Confirm is how long the door must stay open, beyond the contact's own
+	// debounce, before it is taken as forced
+	Confirm time.Duration
+	// Alarm is how long the pattern is shown
+	Alarm   time.Duration
+	Pattern Pattern
+}
+
+// DefaultTamper flashes red with the siren, alternating with white, for two
+// minutes
+var DefaultTamper = TamperConfig{
+	Confirm: 2 * time.Second,
+	Alarm:   2 * time.Minute,
+	Pattern: Pattern{
+		{Outputs: []string{string(red), string(siren)}, Duration: 250 * time.Millisecond},
+		{Outputs: []string{string(white)}, Duration: 250 * time.Millisecond},
+	},
+}
+
+// TamperMonitor raises the alarm when the door is opened without having been
+// unlocked
+type TamperMonitor struct {
+	clock   Clock
+	config  TamperConfig
+	display Display
+	phase   func() Phase
+	alarmFn func(at time.Time)
+
+	mu         sync.Mutex
+	open       bool
+	alarmed    bool
+	confirm    Timer
+	generation int
+}
+
+// NewTamperMonitor creates a monitor of the door, whose phase is read to tell a
+// forced door from one that was unlocked, showing the alarm on the display;
+// call Watch to drive it from the input edges
+func NewTamperMonitor(clock Clock, config TamperConfig, display Display, phase func() Phase, alarmFn func(at time.Time)) *TamperMonitor {
+	return &TamperMonitor{
+		clock:   clock,
+		config:  config,
+		display: display,
+		phase:   phase,
+		alarmFn: alarmFn,
+	}
+}
+
+// Watch handles the contact edges until the channel closes
+func (m *TamperMonitor) Watch(edges <-chan Edge) {
+	for edge := range edges {
+		switch edge.Kind {
+		case DoorOpened:
+			m.Opened()
+		case DoorClosed:
+			m.Closed()
+		}
+	}
+}
+
+// Opened handles the door being opened, suspecting it was forced if the door
+// was locked
+func (m *TamperMonitor) Opened() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open = true
+	switch m.phase() {
+	case Idle, Verifying, Rejected:
+	default:
+		return
+	}
+	log.Println("DOOR: Opened while locked, confirming")
+	m.generation++
+	generation := m.generation
+	m.confirm = m.clock.AfterFunc(m.config.Confirm, func() { m.confirmed(generation) })
+}
+
+// Closed handles the door being closed, dismissing an unconfirmed opening as
+// a glitch
+func (m *TamperMonitor) Closed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open = false
+	m.alarmed = false
+	if m.confirm != nil {
+		log.Println("DOOR: Closed before opening was confirmed, ignoring")
+		m.confirm.Stop()
+		m.confirm = nil
+	}
+	m.generation++
+}
+
+func (m *TamperMonitor) confirmed(generation int) {
+	m.mu.Lock()
+	if generation != m.generation || !m.open || m.alarmed {
+		m.mu.Unlock()
+		return
+	}
+	m.confirm = nil
+	m.alarmed = true
+	m.mu.Unlock()
+
+	at := m.clock.Now()
+	log.Println("DOOR: Forced open")
+	m.alarmFn(at)
+	m.display.Show(tamperLayer, AlarmPriority, m.config.Pattern, m.config.Alarm)
+}
@@ -0,0 +1,76 @@
+package door
+
+import (
+	"testing"
+	"time"
+)
+
+var tamperConfig = TamperConfig{
+	Confirm: 2 * time.Second,
+	Alarm:   time.Second,
+	Pattern: Pattern{
+		{Outputs: []string{"red", "siren"}, Duration: 500 * time.Millisecond},
+		{Duration: 500 * time.Millisecond},
+	},
+}
+
+func TestTamperForced(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	outputs := &fakeOutputs{levels: map[string]bool{}}
+	alarms := []time.Time{}
+	m := NewTamperMonitor(clock, tamperConfig, NewIndicator(clock, outputs), func() Phase { return Idle },
+		func(at time.Time) { alarms = append(alarms, at) })
+
+	m.Opened()
+	clock.Advance(2 * time.Second)
+	if len(alarms) != 1 {
+		t.Errorf("Expected an alarm, got %v\n", alarms)
+	}
+	if !outputs.levels["red"] || !outputs.levels["siren"] {
+		t.Errorf("Expected the pattern to be shown, got %v\n", outputs.levels)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if outputs.levels["red"] || outputs.levels["siren"] {
+		t.Errorf("Expected the second step to turn the first off, got %v\n", outputs.levels)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if outputs.levels["red"] || outputs.restores != 1 {
+		t.Errorf("Expected the pattern to end and outputs restored, got %v\n", outputs)
+	}
+
+	m.Opened()
+	clock.Advance(2 * time.Second)
+	if len(alarms) != 1 {
+		t.Errorf("Expected a single alarm until the door closes, got %v\n", alarms)
+	}
+}
+
+func TestTamperGlitch(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	outputs := &fakeOutputs{levels: map[string]bool{}}
+	alarms := []time.Time{}
+	m := NewTamperMonitor(clock, tamperConfig, NewIndicator(clock, outputs), func() Phase { return Idle },
+		func(at time.Time) { alarms = append(alarms, at) })
+
+	m.Opened()
+	clock.Advance(time.Second)
+	m.Closed()
+	clock.Advance(2 * time.Second)
+	if len(alarms) != 0 {
+		t.Errorf("Expected a brief opening to be ignored, got %v\n", alarms)
+	}
+}
+
+func TestTamperUnlocked(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)}
+	outputs := &fakeOutputs{levels: map[string]bool{}}
+	alarms := []time.Time{}
+	m := NewTamperMonitor(clock, tamperConfig, NewIndicator(clock, outputs), func() Phase { return Unlocked },
+		func(at time.Time) { alarms = append(alarms, at) })
+
+	m.Opened()
+	clock.Advance(5 * time.Second)
+	if len(alarms) != 0 {
+		t.Errorf("Expected no alarm for an unlocked door, got %v\n", alarms)
+	}
+}
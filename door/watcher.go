@@ -0,0 +1,167 @@
+package door
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// EdgeKind is the change published by a Watcher
+type EdgeKind string
+
+const (
+	DoorOpened       EdgeKind = "opened"
+	DoorClosed       EdgeKind = "closed"
+	OverridePressed  EdgeKind = "override_pressed"
+	OverrideReleased EdgeKind = "override_released"
+	InputFault       EdgeKind = "fault"
+	InputRestored    EdgeKind = "restored"
+
+	// ContactInput and OverrideInput name the inputs of an Edge
+	ContactInput  = "contact"
+	OverrideInput = "override"
+
+	subscriberBuffer = 16
+)
+
+// Edge is a debounced change of an input
+type Edge struct {
+	Kind  EdgeKind
+	Input string
+	At    time.Time
+	Err   error
+}
+
+// Debounce sets how often inputs are sampled, and how long a change must hold
+// before it is published
+type Debounce struct {
+	Interval time.Duration
+	Contact  time.Duration
+	Override time.Duration
+}
+
+// DefaultDebounce suits the reed contact and push button of the original build
+var DefaultDebounce = Debounce{
+	Interval: 20 * time.Millisecond,
+	Contact:  100 * time.Millisecond,
+	Override: 50 * time.Millisecond,
+}
+
+// Watcher samples the door's inputs and publishes their debounced edges to
+// its subscribers
+type Watcher struct {
+	clock    Clock
+	interval time.Duration
+	inputs   []*watchedInput
+
+	mu          sync.Mutex
+	subscribers []chan Edge
+}
+
+type watchedInput struct {
+	name     input
+	sensor   Sensor
+	debounce time.Duration
+	onEdge   EdgeKind
+	offEdge  EdgeKind
+
+	known     bool
+	stable    bool
+	pending   bool
+	candidate bool
+	since     time.Time
+	faulted   bool
+}
+
+// NewWatcher creates a watcher of the contact and override sensors; call Run
+// to start sampling
+func NewWatcher(clock Clock, debounce Debounce, contactSensor, overrideSensor Sensor) *Watcher {
+	return &Watcher{
+		clock:    clock,
+		interval: debounce.Interval,
+		inputs: []*watchedInput{
+			{name: contact, sensor: contactSensor, debounce: debounce.Contact, onEdge: DoorOpened, offEdge: DoorClosed},
+			{name: override, sensor: overrideSensor, debounce: debounce.Override, onEdge: OverridePressed, offEdge: OverrideReleased},
+		},
+	}
+}
+
+// Subscribe returns a channel of every edge published from now on. Edges are
+// delivered in order, and a subscriber that falls behind holds up sampling.
+func (w *Watcher) Subscribe() <-chan Edge {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ch := make(chan Edge, subscriberBuffer)
+	w.subscribers = append(w.subscribers, ch)
+	return ch
+}
+
+// Run samples the inputs until the process exits
+func (w *Watcher) Run() {
+	for {
+		w.Sample(w.clock.Now())
+		time.Sleep(w.interval)
+	}
+}
+
+// Sample reads every input once, publishing any change that has now held for
+// its debounce period. The first reading of an input is taken as its state.
+func (w *Watcher) Sample(now time.Time) {
+	for _, in := range w.inputs {
+		if edge, ok := in.sample(now); ok {
+			w.publish(edge)
+		}
+	}
+}
+
+func (in *watchedInput) sample(now time.Time) (Edge, bool) {
+	active, err := in.sensor.Active()
+	if err != nil {
+		if in.faulted {
+			return Edge{}, false
+		}
+		log.Printf("DOOR: Error reading %v: %v\n", in.name, err)
+		in.faulted = true
+		in.pending = false
+		return Edge{Kind: InputFault, Input: string(in.name), At: now, Err: err}, true
+	}
+	if in.faulted {
+		in.faulted = false
+		in.known = true
+		in.stable = active
+		return Edge{Kind: InputRestored, Input: string(in.name), At: now}, true
+	}
+	if !in.known {
+		in.known = true
+		in.stable = active
+		return Edge{}, false
+	}
+	if active == in.stable {
+		in.pending = false
+		return Edge{}, false
+	}
+	if !in.pending || in.candidate != active {
+		in.pending = true
+		in.candidate = active
+		in.since = now
+	}
+	if now.Sub(in.since) < in.debounce {
+		return Edge{}, false
+	}
+	in.pending = false
+	in.stable = active
+	kind := in.offEdge
+	if active {
+		kind = in.onEdge
+	}
+	return Edge{Kind: kind, Input: string(in.name), At: now}, true
+}
+
+func (w *Watcher) publish(edge Edge) {
+	w.mu.Lock()
+	subscribers := w.subscribers
+	w.mu.Unlock()
+	for _, ch := range subscribers {
+		ch <- edge
+	}
+}
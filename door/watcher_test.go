@@ -0,0 +1,84 @@
+package door
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func received(edges <-chan Edge) []Edge {
+	all := []Edge{}
+	for {
+		select {
+		case edge := <-edges:
+			all = append(all, edge)
+		default:
+			return all
+		}
+	}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	contactSensor := &fakeSensor{}
+	overrideSensor := &fakeSensor{}
+	w := NewWatcher(SystemClock{}, DefaultDebounce, contactSensor, overrideSensor)
+	edges := w.Subscribe()
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+
+	w.Sample(now)
+	if len(received(edges)) != 0 {
+		t.Errorf("Expected the first sample to publish nothing\n")
+	}
+
+	// bounce shorter than the debounce period
+	contactSensor.active = true
+	w.Sample(now.Add(20 * time.Millisecond))
+	contactSensor.active = false
+	w.Sample(now.Add(40 * time.Millisecond))
+	contactSensor.active = true
+	w.Sample(now.Add(60 * time.Millisecond))
+	w.Sample(now.Add(140 * time.Millisecond))
+	if len(received(edges)) != 0 {
+		t.Errorf("Expected bounce to be ignored\n")
+	}
+
+	w.Sample(now.Add(160 * time.Millisecond))
+	got := received(edges)
+	if len(got) != 1 || got[0].Kind != DoorOpened || got[0].Input != ContactInput {
+		t.Errorf("Expected a single opened edge, got %v\n", got)
+	}
+	w.Sample(now.Add(300 * time.Millisecond))
+	if len(received(edges)) != 0 {
+		t.Errorf("Expected no repeated edge\n")
+	}
+
+	overrideSensor.active = true
+	w.Sample(now.Add(320 * time.Millisecond))
+	w.Sample(now.Add(370 * time.Millisecond))
+	got = received(edges)
+	if len(got) != 1 || got[0].Kind != OverridePressed {
+		t.Errorf("Expected override pressed, got %v\n", got)
+	}
+}
+
+func TestWatcherFault(t *testing.T) {
+	contactSensor := &fakeSensor{}
+	w := NewWatcher(SystemClock{}, DefaultDebounce, contactSensor, &fakeSensor{})
+	edges := w.Subscribe()
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+
+	w.Sample(now)
+	contactSensor.err = errors.New("read failed")
+	w.Sample(now.Add(20 * time.Millisecond))
+	w.Sample(now.Add(40 * time.Millisecond))
+	got := received(edges)
+	if len(got) != 1 || got[0].Kind != InputFault || got[0].Err == nil {
+		t.Errorf("Expected a single fault, got %v\n", got)
+	}
+	contactSensor.err = nil
+	w.Sample(now.Add(60 * time.Millisecond))
+	got = received(edges)
+	if len(got) != 1 || got[0].Kind != InputRestored {
+		t.Errorf("Expected restored, got %v\n", got)
+	}
+}
@@ -5,7 +5,6 @@ go 1.18
 require (
 	github.com/aws/aws-sdk-go v1.44.95
 	github.com/boltdb/bolt v1.3.1
-	github.com/cpucycle/astrotime v0.0.0-20120927164819-9c7d514efdb5
 	github.com/luismesas/goPi v1.0.3-0.20140419091954-abc9b85cfb5f
 	github.com/pquerna/otp v1.3.0
 )
@@ -2,6 +2,7 @@ package keypad
 
 import (
 	"bytes"
+	"io"
 	"log"
 	"time"
 )
@@ -16,6 +17,8 @@ const (
 	Partial = "partial"
 	// Final z
 	Final = "final"
+	// Card is a card's ID, read in one go
+	Card = "card"
 )
 
 // Code is
@@ -24,11 +27,25 @@ type Code struct {
 	Submitted SubmissionType
 }
 
-// ScanCodes x
-func ScanCodes(device string, autoClear time.Duration, maxLength int, codeFn func(Code), timeoutFn func(string), errFn func(error)) {
-	var keys = make(chan keyEvent)
+// Complete reports whether the code was submitted, rather than still being
+// entered
+func (c Code) Complete() bool {
+	return c.Submitted != Partial
+}
+
+// ScanCodes reads codes from the source's keys, calling errFn if it fails. A
+// source that ends, e.g. stdin at EOF, has not failed.
+func ScanCodes(source KeySource, autoClear time.Duration, maxLength int, codeFn func(Code), timeoutFn func(string), errFn func(error)) {
+	var keys = make(chan Key)
 
-	go ScanKeys(device, keys, errFn)
+	go func() {
+		err := source.Keys(keys)
+		if err == nil || err == io.EOF {
+			log.Printf("KEYPAD: Key source ended: %v\n", err)
+			return
+		}
+		errFn(err)
+	}()
 
 	var scanBuffer bytes.Buffer
 	var autoClearTimer *time.Timer
@@ -45,7 +62,14 @@ func ScanCodes(device string, autoClear time.Duration, maxLength int, codeFn fun
 			if autoClearTimer != nil {
 				autoClearTimer.Stop()
 			}
-			if k.Enter {
+			if k.Card != "" {
+				log.Printf("KEYPAD: Card read: %v\n", k.Card)
+				scanBuffer.Reset()
+				codeFn(Code{
+					Digits:    k.Card,
+					Submitted: Card,
+				})
+			} else if k.Enter {
 				if scanBuffer.Len() > 0 {
 					log.Printf("KEYPAD: User submitted code: %v\n", scanBuffer.String())
 					codeFn(Code{
@@ -57,6 +81,13 @@ func ScanCodes(device string, autoClear time.Duration, maxLength int, codeFn fun
 			} else if k.Clear {
 				log.Println("KEYPAD: Manual clear")
 				scanBuffer.Reset()
+			} else if k.Backspace {
+				if scanBuffer.Len() > 0 {
+					scanBuffer.Truncate(scanBuffer.Len() - 1)
+				}
+				if scanBuffer.Len() > 0 {
+					autoClearTimer = startTimer(autoClear, reset)
+				}
 			} else {
 				scanBuffer.WriteString(k.Digit)
 				if scanBuffer.Len() >= maxLength {
@@ -0,0 +1,77 @@
+package keypad
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Where input devices are listed by name, and described, on Linux
+var (
+	byIDDir     = "/dev/input/by-id"
+	sysInputDir = "/sys/class/input"
+	devInputDir = "/dev/input"
+)
+
+// ErrNoDevice is returned when no input device matches
+var ErrNoDevice = errors.New("KEYPAD: no matching input device")
+
+// Locate returns a function that finds the device each time it is called: a
+// path, name=<part of its name> or id=<vendor>:<product>
+func Locate(device string) (func() (string, error), error) {
+	switch {
+	case strings.HasPrefix(device, "name="):
+		name := strings.TrimPrefix(device, "name=")
+		return func() (string, error) { return FindDevice(name) }, nil
+	case strings.HasPrefix(device, "id="):
+		vendor, product, ok := strings.Cut(strings.TrimPrefix(device, "id="), ":")
+		if !ok {
+			return nil, fmt.Errorf("KEYPAD: expected id=<vendor>:<product>: %q", device)
+		}
+		return func() (string, error) { return FindDeviceID(vendor, product) }, nil
+	}
+	return func() (string, error) { return device, nil }, nil
+}
+
+// FindDevice finds the keyboard event device under /dev/input/by-id whose name
+// contains name, ignoring case, e.g. "usb-Storm_Keypad-event-kbd"
+func FindDevice(name string) (string, error) {
+	links, err := filepath.Glob(filepath.Join(byIDDir, "*-event-*"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(links)
+	for _, link := range links {
+		if strings.Contains(strings.ToLower(filepath.Base(link)), strings.ToLower(name)) {
+			return link, nil
+		}
+	}
+	return "", fmt.Errorf("%w: named %q", ErrNoDevice, name)
+}
+
+// FindDeviceID finds the event device of the USB vendor and product, in hex,
+// e.g. 05a4:9881
+func FindDeviceID(vendor, product string) (string, error) {
+	events, err := filepath.Glob(filepath.Join(sysInputDir, "event*"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(events)
+	for _, event := range events {
+		if readID(event, "vendor") == strings.ToLower(vendor) && readID(event, "product") == strings.ToLower(product) {
+			return filepath.Join(devInputDir, filepath.Base(event)), nil
+		}
+	}
+	return "", fmt.Errorf("%w: id %v:%v", ErrNoDevice, vendor, product)
+}
+
+func readID(event, field string) string {
+	data, err := os.ReadFile(filepath.Join(event, "device", "id", field))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,124 @@
+package keypad
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindDevice(t *testing.T) {
+	dir := t.TempDir()
+	byIDDir, sysInputDir, devInputDir = filepath.Join(dir, "by-id"), filepath.Join(dir, "sys"), filepath.Join(dir, "dev")
+	defer func() {
+		byIDDir, sysInputDir, devInputDir = "/dev/input/by-id", "/sys/class/input", "/dev/input"
+	}()
+	os.MkdirAll(byIDDir, 0700)
+	for _, name := range []string{"usb-Logitech_USB_Receiver-event-mouse", "usb-Storm_Interface_Keypad-event-kbd"} {
+		os.WriteFile(filepath.Join(byIDDir, name), nil, 0600)
+	}
+	for event, id := range map[string][2]string{"event0": {"046d", "c52b"}, "event3": {"05A4", "9881"}} {
+		os.MkdirAll(filepath.Join(sysInputDir, event, "device", "id"), 0700)
+		os.WriteFile(filepath.Join(sysInputDir, event, "device", "id", "vendor"), []byte(id[0]+"\n"), 0600)
+		os.WriteFile(filepath.Join(sysInputDir, event, "device", "id", "product"), []byte(id[1]+"\n"), 0600)
+	}
+
+	find, _ := Locate("name=storm")
+	if path, err := find(); err != nil || path != filepath.Join(byIDDir, "usb-Storm_Interface_Keypad-event-kbd") {
+		t.Errorf("Expected the keypad by name but %v, %v\n", path, err)
+	}
+	find, _ = Locate("id=05a4:9881")
+	if path, err := find(); err != nil || path != filepath.Join(devInputDir, "event3") {
+		t.Errorf("Expected the keypad by id but %v, %v\n", path, err)
+	}
+	find, _ = Locate("name=Cherry")
+	if _, err := find(); !errors.Is(err, ErrNoDevice) {
+		t.Errorf("Expected no device but %v\n", err)
+	}
+	find, _ = Locate("/dev/input/event7")
+	if path, _ := find(); path != "/dev/input/event7" {
+		t.Errorf("Expected the path but %v\n", path)
+	}
+	if _, err := Locate("id=05a4"); err == nil {
+		t.Errorf("Expected error for id without product\n")
+	}
+}
+
+type status struct {
+	online bool
+	err    error
+}
+
+func TestReconnect(t *testing.T) {
+	evdevRetry, evdevMaxRetry = time.Millisecond, 4*time.Millisecond
+	defer func() { evdevRetry, evdevMaxRetry = time.Second, 30*time.Second }()
+
+	opened := make(chan *os.File, 4)
+	attempts := 0
+	open := func() (io.ReadCloser, error) {
+		attempts++
+		if attempts <= 2 {
+			return nil, ErrNoDevice
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		opened <- w
+		return r, nil
+	}
+	statuses := make(chan status, 8)
+	keymap, _ := LoadKeymap("numpad")
+	source := newEvdev(open, keymap, func(online bool, err error) { statuses <- status{online, err} })
+
+	keys := make(chan Key, 8)
+	done := make(chan error)
+	go func() { done <- source.Keys(keys) }()
+
+	expectStatus := func(online bool) {
+		select {
+		case s := <-statuses:
+			if s.online != online {
+				t.Errorf("Expected online %v but %v\n", online, s)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected online %v\n", online)
+		}
+	}
+	pressKey := func(w *os.File, code uint16) {
+		w.Write(press(NativeDecoder(), code))
+		select {
+		case <-keys:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected key %v\n", code)
+		}
+	}
+
+	// Missing at first, reported once however many attempts fail
+	expectStatus(false)
+	expectStatus(true)
+	w := <-opened
+	pressKey(w, 79)
+
+	// Unplugged, then found again
+	w.Close()
+	expectStatus(false)
+	expectStatus(true)
+	w = <-opened
+	pressKey(w, 80)
+
+	source.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error when closed but %v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected Keys to return when closed\n")
+	}
+	if len(statuses) != 0 {
+		t.Errorf("Expected no status when closed but %v\n", <-statuses)
+	}
+}
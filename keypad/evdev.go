@@ -0,0 +1,144 @@
+package keypad
+
+import (
+	"encoding/binary"
+	"log"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// Event types and codes, from linux/input-event-codes.h
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+
+	SynReport  = 0
+	SynDropped = 3
+
+	// KeyPress is the value of an EvKey event when a key goes down, rather than
+	// up (0) or repeating (2)
+	KeyPress = 1
+)
+
+// Event is an input event read from an evdev device
+type Event struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// Decoder decodes the kernel's struct input_event, as described in
+// https://www.kernel.org/doc/Documentation/input/input.txt, from the bytes
+// read from an evdev device, whatever size the reads are. The struct starts
+// with a timeval of two longs, so it is 24 bytes on 64-bit platforms and 16 on
+// 32-bit ones such as the Pi's ARM.
+//
+// Events are returned a packet at a time, once the packet's SYN_REPORT is
+// read. After a SYN_DROPPED, when the kernel's buffer overflowed, events are
+// dropped up to the next SYN_REPORT as the evdev protocol requires.
+type Decoder struct {
+	wordSize int
+	order    binary.ByteOrder
+	partial  []byte
+	packet   []Event
+	dropping bool
+	dropped  int
+}
+
+// NewDecoder decodes events for a platform with the word size, 4 or 8, and
+// byte order
+func NewDecoder(wordSize int, order binary.ByteOrder) *Decoder {
+	return &Decoder{wordSize: wordSize, order: order}
+}
+
+// NativeDecoder decodes events for the platform this is running on
+func NativeDecoder() *Decoder {
+	return NewDecoder(strconv.IntSize/8, nativeOrder())
+}
+
+func nativeOrder() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// EventSize is the size of an event in bytes
+func (d *Decoder) EventSize() int {
+	return 2*d.wordSize + 8
+}
+
+// Dropped counts the packets dropped after a SYN_DROPPED
+func (d *Decoder) Dropped() int {
+	return d.dropped
+}
+
+// Decode decodes the bytes read, keeping any partial event until the rest is
+// read, and returns the events of each packet completed. SYN events are not
+// returned.
+func (d *Decoder) Decode(data []byte) []Event {
+	d.partial = append(d.partial, data...)
+	size := d.EventSize()
+	var events []Event
+	n := 0
+	for ; n+size <= len(d.partial); n += size {
+		e := d.event(d.partial[n : n+size])
+		switch {
+		case e.Type == EvSyn && e.Code == SynDropped:
+			log.Println("KEYPAD: Input events dropped")
+			d.packet = nil
+			d.dropping = true
+		case e.Type == EvSyn && e.Code == SynReport:
+			if d.dropping {
+				d.dropped++
+				d.dropping = false
+			} else {
+				events = append(events, d.packet...)
+			}
+			d.packet = nil
+		case e.Type == EvSyn || d.dropping:
+		default:
+			d.packet = append(d.packet, e)
+		}
+	}
+	d.partial = append(d.partial[:0], d.partial[n:]...)
+	return events
+}
+
+func (d *Decoder) event(b []byte) Event {
+	var sec, usec int64
+	if d.wordSize == 8 {
+		sec, usec = int64(d.order.Uint64(b)), int64(d.order.Uint64(b[8:]))
+	} else {
+		sec, usec = int64(int32(d.order.Uint32(b))), int64(int32(d.order.Uint32(b[4:])))
+	}
+	b = b[2*d.wordSize:]
+	return Event{
+		Time:  time.Unix(sec, usec*int64(time.Microsecond)),
+		Type:  d.order.Uint16(b),
+		Code:  d.order.Uint16(b[2:]),
+		Value: int32(d.order.Uint32(b[4:])),
+	}
+}
+
+// Encode encodes an event as the kernel would, e.g. to record or replay a
+// keypad in tests
+func (d *Decoder) Encode(e Event) []byte {
+	b := make([]byte, d.EventSize())
+	usec := e.Time.Nanosecond() / int(time.Microsecond)
+	if d.wordSize == 8 {
+		d.order.PutUint64(b, uint64(e.Time.Unix()))
+		d.order.PutUint64(b[8:], uint64(usec))
+	} else {
+		d.order.PutUint32(b, uint32(e.Time.Unix()))
+		d.order.PutUint32(b[4:], uint32(usec))
+	}
+	rest := b[2*d.wordSize:]
+	d.order.PutUint16(rest, e.Type)
+	d.order.PutUint16(rest[2:], e.Code)
+	d.order.PutUint32(rest[4:], uint32(e.Value))
+	return b
+}
@@ -0,0 +1,25 @@
+package keypad
+
+import (
+	"io"
+	"log"
+	"os"
+	"syscall"
+)
+
+// eviocgrab is _IOW('E', 0x90, int)
+const eviocgrab = 0x40044590
+
+// openGrabbed opens the input device, grabbing it so that nothing else, such
+// as the console, sees its keys
+func openGrabbed(path string) (io.ReadCloser, error) {
+	dev, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dev.Fd(), eviocgrab, 1)
+	if errno != 0 {
+		log.Printf("KEYPAD: Could not grab %v: %v\n", path, errno)
+	}
+	return dev, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package keypad
+
+import (
+	"io"
+	"os"
+)
+
+// openGrabbed opens the input device, which can only be grabbed on Linux
+func openGrabbed(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,103 @@
+package keypad
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var keyTime = time.Unix(1510556400, 123456000)
+
+// press encodes a key's press and release, each with its SYN_REPORT
+func press(d *Decoder, codes ...uint16) []byte {
+	var b []byte
+	for _, code := range codes {
+		for _, value := range []int32{KeyPress, 0} {
+			b = append(b, d.Encode(Event{Time: keyTime, Type: EvKey, Code: code, Value: value})...)
+			b = append(b, d.Encode(Event{Time: keyTime, Type: EvSyn, Code: SynReport})...)
+		}
+	}
+	return b
+}
+
+func TestDecoderLayouts(t *testing.T) {
+	for _, c := range []struct {
+		wordSize int
+		size     int
+	}{{4, 16}, {8, 24}} {
+		d := NewDecoder(c.wordSize, binary.LittleEndian)
+		if d.EventSize() != c.size {
+			t.Errorf("Expected %v-bit events of %v bytes but %v\n", c.wordSize*8, c.size, d.EventSize())
+		}
+		events := d.Decode(press(d, 30))
+		if len(events) != 2 {
+			t.Fatalf("Expected press and release but %v\n", events)
+		}
+		e := events[0]
+		if e.Type != EvKey || e.Code != 30 || e.Value != KeyPress || !e.Time.Equal(keyTime) {
+			t.Errorf("Expected key 30 pressed at %v but %v\n", keyTime, e)
+		}
+	}
+
+	// A 24 byte event from a 64-bit kernel
+	raw := []byte{
+		0xf0, 0x47, 0x09, 0x5a, 0, 0, 0, 0, 0x40, 0xe2, 0x01, 0, 0, 0, 0, 0,
+		0x01, 0x00, 0x1e, 0x00, 0x01, 0x00, 0x00, 0x00,
+	}
+	raw = append(raw, make([]byte, 24)...)
+	events := NewDecoder(8, binary.LittleEndian).Decode(raw)
+	if len(events) != 1 || events[0].Code != 30 || events[0].Time.Unix() != 1510557680 || events[0].Time.Nanosecond() != 123456000 {
+		t.Errorf("Expected key 30 from the raw event but %v\n", events)
+	}
+
+	if NativeDecoder().EventSize() != 2*strconv.IntSize/8+8 {
+		t.Errorf("Expected native events sized for the platform\n")
+	}
+}
+
+func TestDecoderShortReads(t *testing.T) {
+	d := NewDecoder(4, binary.LittleEndian)
+	data := press(d, 2, 3)
+	var codes []uint16
+	// One byte at a time, so that no read holds a whole event
+	for i := range data {
+		for _, e := range d.Decode(data[i : i+1]) {
+			if e.Value == KeyPress {
+				codes = append(codes, e.Code)
+			}
+		}
+	}
+	if len(codes) != 2 || codes[0] != 2 || codes[1] != 3 {
+		t.Errorf("Expected keys 2 and 3 but %v\n", codes)
+	}
+}
+
+func TestDecoderPackets(t *testing.T) {
+	d := NewDecoder(8, binary.LittleEndian)
+	key := d.Encode(Event{Time: keyTime, Type: EvKey, Code: 4, Value: KeyPress})
+	if events := d.Decode(key); len(events) != 0 {
+		t.Errorf("Expected nothing until SYN_REPORT but %v\n", events)
+	}
+	if events := d.Decode(d.Encode(Event{Type: EvSyn, Code: SynReport})); len(events) != 1 || events[0].Code != 4 {
+		t.Errorf("Expected the packet's key but %v\n", events)
+	}
+}
+
+func TestDecoderDropped(t *testing.T) {
+	d := NewDecoder(8, binary.LittleEndian)
+	var data []byte
+	data = append(data, d.Encode(Event{Time: keyTime, Type: EvKey, Code: 5, Value: KeyPress})...)
+	data = append(data, d.Encode(Event{Time: keyTime, Type: EvSyn, Code: SynDropped})...)
+	data = append(data, d.Encode(Event{Time: keyTime, Type: EvKey, Code: 6, Value: KeyPress})...)
+	data = append(data, d.Encode(Event{Time: keyTime, Type: EvSyn, Code: SynReport})...)
+	data = append(data, press(d, 7)...)
+
+	events := d.Decode(data)
+	if len(events) != 2 || events[0].Code != 7 {
+		t.Errorf("Expected only key 7 after the drop but %v\n", events)
+	}
+	if d.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped packet but %v\n", d.Dropped())
+	}
+}
@@ -16,138 +16,90 @@
 package keypad
 
 import (
-	"bytes"
-	"encoding/binary"
-	"fmt"
-	"os"
-	"syscall"
-	"unsafe"
+	"io"
+	"log"
+	"time"
 )
 
 const (
-	shiftCode     = 42
-	clearCode     = 9
-	enterCode     = 4
 	eventCaptures = 16
 	// DefaultDevice location on the Pi
 	DefaultDevice = "/dev/input/event0"
 )
 
-// InputEvent is a Go implementation of the native linux device
-// input_event struct, as described in the kernel documentation
-// (https://www.kernel.org/doc/Documentation/input/input.txt),
-// with a big assist from https://github.com/gvalkov/golang-evdev
-type inputEvent struct {
-	Time  syscall.Timeval
-	Type  uint16
-	Code  uint16
-	Value int32
-}
-
-type keyEvent struct {
-	Digit   string
-	Enter   bool
-	Clear   bool
-	Timeout bool
-}
-
-var eventSize = int(unsafe.Sizeof(inputEvent{}))
-
-// KEYCODES is the map of hex found in the InputEvent.Code field, and
-// its corresponding char (string) representation
-// [source: Vojtech Pavlik (author of the Linux Input Drivers project),
-// via linuxquestions.org user bricedebrignaisplage]
-var KEYCODES = map[byte]string{
-	0x02: "1",
-	0x03: "2",
-	0x04: "3",
-	0x05: "4",
-	0x06: "5",
-	0x07: "6",
-	0x08: "7",
-	0x09: "8",
-	0x0a: "9",
-	0x0b: "0",
-}
+// evdevRetry is the first wait before opening a lost keypad again, doubling up
+// to evdevMaxRetry
+var (
+	evdevRetry    = time.Second
+	evdevMaxRetry = 30 * time.Second
+)
 
-// lookupKeyCode finds the corresponding string for the given hex byte,
-// returning "-" as the default if not found
-func lookupKeyCode(b byte) string {
-	val, exists := KEYCODES[b]
-	if exists {
-		return val
-	}
-	fmt.Printf("unknown: %x\n", b)
-	return "-"
+// EvdevSource reads keys from a keypad that is a linux input device. The
+// device is grabbed, so that its keys do not also reach the console, and is
+// found and opened again if it is lost, e.g. unplugged.
+type EvdevSource struct {
+	reopener
+	open   func() (io.ReadCloser, error)
+	keymap *Keymap
 }
 
-// read takes the open scanner device pointer and returns a list of
-// inputEvent captures, corresponding to input (scan) events
-func read(dev *os.File) ([]inputEvent, error) {
-	events := make([]inputEvent, eventCaptures)
-	buffer := make([]byte, eventSize*eventCaptures)
-	_, err := dev.Read(buffer)
-	if err != nil {
-		return events, err
-	}
-	b := bytes.NewBuffer(buffer)
-	err = binary.Read(b, binary.LittleEndian, &events)
+// OpenEvdev reads keys from the input device, mapping its key codes with the
+// keymap. The device is a path, e.g. DefaultDevice, name=<part of its name>
+// or id=<vendor>:<product>, see FindDevice. statusFn is told when the keypad
+// is opened, and when it is lost.
+func OpenEvdev(device string, keymap *Keymap, statusFn func(online bool, err error)) (*EvdevSource, error) {
+	find, err := Locate(device)
 	if err != nil {
-		return events, err
+		return nil, err
 	}
-	// remove trailing structures
-	for i := range events {
-		if events[i].Time.Sec == 0 {
-			events = append(events[:i])
-			break
+	return newEvdev(func() (io.ReadCloser, error) {
+		path, err := find()
+		if err != nil {
+			return nil, err
 		}
-	}
-	return events, err
+		return openGrabbed(path)
+	}, keymap, statusFn), nil
 }
 
-// ScanKeys takes a linux input device string pointing to the scanner
-// to read from, invokes the given function on the resulting barcode string
-// when complete, or the errfn on error, then goes back to read/scan again
-func ScanKeys(device string, keys chan<- keyEvent, errFn func(error)) {
-	scanner, err := os.Open(device)
-	if err != nil {
-		// invoke the function which handles scanner errors
-		errFn(err)
-	}
-	defer scanner.Close()
+func newEvdev(open func() (io.ReadCloser, error), keymap *Keymap, statusFn func(bool, error)) *EvdevSource {
+	return &EvdevSource{reopener: newReopener(statusFn), open: open, keymap: keymap}
+}
+
+// Keys reads the keypad until the source is closed, waiting for it to be
+// opened again whenever it is lost
+func (s *EvdevSource) Keys(keys chan<- Key) error {
+	return s.run(func() (io.Closer, error) {
+		return s.open()
+	}, func(dev io.Closer) error {
+		return s.read(dev.(io.Reader), keys)
+	})
+}
 
+// read reads the device's key presses, sending the keys they map to. Shift
+// applies to the next key pressed.
+func (s *EvdevSource) read(dev io.Reader, keys chan<- Key) error {
+	decoder := NativeDecoder()
+	buffer := make([]byte, decoder.EventSize()*eventCaptures)
 	shift := false
 	for {
-		events, scanErr := read(scanner)
-		if scanErr != nil {
-			// invoke the function which handles scanner errors
-			errFn(scanErr)
-		}
-		for i := range events {
-			if events[i].Type == 1 && events[i].Value == 1 {
-				if events[i].Code == shiftCode {
-					shift = true
-				} else {
-					if shift {
-						if events[i].Code == enterCode {
-							keys <- keyEvent{
-								Enter: true,
-							}
-						} else if events[i].Code == clearCode {
-							keys <- keyEvent{
-								Clear: true,
-							}
-						}
-					} else {
-						if events[i].Code != 0 {
-							keys <- keyEvent{
-								Digit: lookupKeyCode(byte(events[i].Code)),
-							}
-						}
-					}
-					shift = false
-				}
+		n, err := dev.Read(buffer)
+		for _, e := range decoder.Decode(buffer[:n]) {
+			if e.Type != EvKey || e.Value != KeyPress || e.Code == 0 {
+				continue
+			}
+			if e.Code == keyLeftShift || e.Code == keyRightShift {
+				shift = true
+				continue
 			}
+			if key, ok := s.keymap.Key(e.Code, shift); ok {
+				keys <- key
+			} else {
+				log.Printf("KEYPAD: Unmapped key code: %v, shift: %v\n", e.Code, shift)
+			}
+			shift = false
+		}
+		if err != nil {
+			return err
 		}
 	}
 }
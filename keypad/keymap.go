@@ -0,0 +1,185 @@
+package keypad
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Keymap maps the key codes of an evdev keypad to keys, with or without shift
+// held
+type Keymap struct {
+	Keys    map[uint16]Key
+	Shifted map[uint16]Key
+}
+
+// Linux key codes, from linux/input-event-codes.h
+const (
+	keyEsc        = 1
+	keyBackspace  = 14
+	keyEnter      = 28
+	keyLeftShift  = 42
+	keyKPAsterisk = 55
+	keyRightShift = 54
+	keyKPMinus    = 74
+	keyKPDot      = 83
+	keyKPEnter    = 96
+	keyDelete     = 111
+)
+
+// topRow are the key codes of the digits 1-9 then 0 along the top of a
+// keyboard
+var topRow = []uint16{2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+
+// numpad are the key codes of the keypad digits 0-9
+var numpad = []uint16{82, 79, 80, 81, 75, 76, 77, 71, 72, 73}
+
+// Keymaps are the built in profiles:
+//
+// shift: the keypad this was built for, which sends the top row digits, with
+// Shift+3 ('#') to enter and Shift+8 ('*') to clear.
+//
+// numpad: a USB numeric keypad, with enter to enter, '*' or '.' to clear and
+// backspace or '-' to delete a digit.
+//
+// keyboard: a full keyboard, with either set of digits, enter or '#' to enter,
+// escape or '*' to clear and backspace or delete to delete a digit.
+var Keymaps = map[string]*Keymap{
+	"shift":    shiftKeymap(),
+	"numpad":   numpadKeymap(),
+	"keyboard": keyboardKeymap(),
+}
+
+// DefaultKeymap is the keymap of the original keypad
+const DefaultKeymap = "shift"
+
+func digits(codes []uint16, keys map[uint16]Key, first int) {
+	for i, code := range codes {
+		keys[code] = Key{Digit: strconv.Itoa((i + first) % 10)}
+	}
+}
+
+func shiftKeymap() *Keymap {
+	k := &Keymap{Keys: map[uint16]Key{}, Shifted: map[uint16]Key{
+		4: {Enter: true},
+		9: {Clear: true},
+	}}
+	digits(topRow, k.Keys, 1)
+	return k
+}
+
+func numpadKeymap() *Keymap {
+	k := &Keymap{Keys: map[uint16]Key{
+		keyKPEnter:    {Enter: true},
+		keyEnter:      {Enter: true},
+		keyKPAsterisk: {Clear: true},
+		keyKPDot:      {Clear: true},
+		keyBackspace:  {Backspace: true},
+		keyKPMinus:    {Backspace: true},
+	}, Shifted: map[uint16]Key{}}
+	digits(numpad, k.Keys, 0)
+	return k
+}
+
+func keyboardKeymap() *Keymap {
+	k := numpadKeymap()
+	digits(topRow, k.Keys, 1)
+	k.Keys[keyEsc] = Key{Clear: true}
+	k.Keys[keyDelete] = Key{Backspace: true}
+	k.Shifted[4] = Key{Enter: true}
+	k.Shifted[9] = Key{Clear: true}
+	return k
+}
+
+// Key looks up the key for the code, with or without shift
+func (k *Keymap) Key(code uint16, shift bool) (Key, bool) {
+	if shift {
+		key, ok := k.Shifted[code]
+		return key, ok
+	}
+	key, ok := k.Keys[code]
+	return key, ok
+}
+
+// keymapConfig is a keymap file, a built in profile with keys mapped over it,
+// e.g. {"profile": "numpad", "keys": {"15": "enter"}, "shifted": {"4": "enter"}}
+type keymapConfig struct {
+	Profile string
+	Keys    map[string]string
+	Shifted map[string]string
+}
+
+// LoadKeymap loads a built in profile by name, or a keymap file, defaulting to
+// DefaultKeymap. A file's keys are mapped to "0"-"9", "enter", "clear" or
+// "backspace".
+func LoadKeymap(nameOrPath string) (*Keymap, error) {
+	if nameOrPath == "" {
+		nameOrPath = DefaultKeymap
+	}
+	if k, ok := Keymaps[nameOrPath]; ok {
+		return k, nil
+	}
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return nil, fmt.Errorf("KEYPAD: unknown keymap %q: %s", nameOrPath, err)
+	}
+	var config keymapConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("KEYPAD: invalid keymap %q: %s", nameOrPath, err)
+	}
+	return config.keymap()
+}
+
+func (c keymapConfig) keymap() (*Keymap, error) {
+	k := &Keymap{Keys: map[uint16]Key{}, Shifted: map[uint16]Key{}}
+	if c.Profile != "" {
+		base, ok := Keymaps[c.Profile]
+		if !ok {
+			return nil, fmt.Errorf("KEYPAD: unknown keymap profile %q", c.Profile)
+		}
+		for code, key := range base.Keys {
+			k.Keys[code] = key
+		}
+		for code, key := range base.Shifted {
+			k.Shifted[code] = key
+		}
+	}
+	if err := mapKeys(c.Keys, k.Keys); err != nil {
+		return nil, err
+	}
+	if err := mapKeys(c.Shifted, k.Shifted); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func mapKeys(config map[string]string, keys map[uint16]Key) error {
+	for code, action := range config {
+		c, err := strconv.ParseUint(code, 10, 16)
+		if err != nil {
+			return fmt.Errorf("KEYPAD: invalid key code %q", code)
+		}
+		key, err := parseAction(action)
+		if err != nil {
+			return err
+		}
+		keys[uint16(c)] = key
+	}
+	return nil
+}
+
+func parseAction(action string) (Key, error) {
+	switch action {
+	case "enter":
+		return Key{Enter: true}, nil
+	case "clear":
+		return Key{Clear: true}, nil
+	case "backspace":
+		return Key{Backspace: true}, nil
+	}
+	if len(action) == 1 && action[0] >= '0' && action[0] <= '9' {
+		return Key{Digit: action}, nil
+	}
+	return Key{}, fmt.Errorf("KEYPAD: invalid key action %q", action)
+}
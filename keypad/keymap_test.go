@@ -0,0 +1,125 @@
+package keypad
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeymaps(t *testing.T) {
+	cases := []struct {
+		keymap string
+		code   uint16
+		shift  bool
+		key    Key
+	}{
+		{"shift", 2, false, Key{Digit: "1"}},
+		{"shift", 11, false, Key{Digit: "0"}},
+		{"shift", 4, true, Key{Enter: true}},
+		{"shift", 9, true, Key{Clear: true}},
+		{"numpad", 82, false, Key{Digit: "0"}},
+		{"numpad", 79, false, Key{Digit: "1"}},
+		{"numpad", 73, false, Key{Digit: "9"}},
+		{"numpad", keyKPEnter, false, Key{Enter: true}},
+		{"numpad", keyKPAsterisk, false, Key{Clear: true}},
+		{"numpad", keyBackspace, false, Key{Backspace: true}},
+		{"keyboard", 10, false, Key{Digit: "9"}},
+		{"keyboard", 76, false, Key{Digit: "5"}},
+		{"keyboard", keyEsc, false, Key{Clear: true}},
+		{"keyboard", 4, true, Key{Enter: true}},
+	}
+	for _, c := range cases {
+		keymap, err := LoadKeymap(c.keymap)
+		if err != nil {
+			t.Fatalf("Expected keymap %v: %v\n", c.keymap, err)
+		}
+		if key, ok := keymap.Key(c.code, c.shift); !ok || key != c.key {
+			t.Errorf("Expected %v %v shift %v to be %v but %v\n", c.keymap, c.code, c.shift, c.key, key)
+		}
+	}
+	if key, _ := Keymaps["shift"].Key(4, false); key.Digit != "3" {
+		t.Errorf("Expected 3 without shift but %v\n", key)
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keymap.json")
+	os.WriteFile(path, []byte(`{"profile": "numpad", "keys": {"15": "enter", "79": "backspace"}, "shifted": {"4": "clear"}}`), 0600)
+
+	keymap, err := LoadKeymap(path)
+	if err != nil {
+		t.Fatalf("Expected keymap to load: %v\n", err)
+	}
+	if key, _ := keymap.Key(15, false); !key.Enter {
+		t.Errorf("Expected tab to enter\n")
+	}
+	if key, _ := keymap.Key(79, false); !key.Backspace {
+		t.Errorf("Expected keypad 1 to be remapped\n")
+	}
+	if key, _ := keymap.Key(80, false); key.Digit != "2" {
+		t.Errorf("Expected the profile's keypad 2\n")
+	}
+	if key, _ := keymap.Key(4, true); !key.Clear {
+		t.Errorf("Expected Shift+3 to clear\n")
+	}
+	if _, ok := Keymaps["numpad"].Key(15, false); ok {
+		t.Errorf("Expected the built in profile to be unchanged\n")
+	}
+
+	for _, config := range []string{
+		`{"profile": "chiclet"}`,
+		`{"keys": {"15": "open"}}`,
+		`{"keys": {"tab": "enter"}}`,
+	} {
+		os.WriteFile(path, []byte(config), 0600)
+		if _, err := LoadKeymap(path); err == nil {
+			t.Errorf("Expected error for %v\n", config)
+		}
+	}
+	if _, err := LoadKeymap("chiclet"); err == nil {
+		t.Errorf("Expected error for unknown keymap\n")
+	}
+}
+
+func TestEvdevKeymap(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Expected pipe: %v\n", err)
+	}
+	keymap, _ := LoadKeymap("numpad")
+	source := newEvdev(nil, keymap, nil)
+
+	w.Write(press(NativeDecoder(), 79, 80, keyBackspace, 81, 30, keyKPEnter))
+	w.Close()
+
+	keys := make(chan Key, 16)
+	source.read(r, keys)
+	close(keys)
+	var got []Key
+	for k := range keys {
+		got = append(got, k)
+	}
+	expectKeys(t, []Key{{Digit: "1"}, {Digit: "2"}, {Backspace: true}, {Digit: "3"}, {Enter: true}}, got)
+}
+
+func TestBackspace(t *testing.T) {
+	codes := make(chan Code, 16)
+	go ScanCodes(NewTextSource(strings.NewReader("12\b3#\b\b4#")), time.Minute, 6, func(c Code) {
+		if c.Complete() {
+			codes <- c
+		}
+	}, func(string) {}, func(error) {})
+
+	for _, e := range []string{"13", "4"} {
+		select {
+		case c := <-codes:
+			if c.Digits != e {
+				t.Errorf("Expected %v but %v\n", e, c.Digits)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %v\n", e)
+		}
+	}
+}
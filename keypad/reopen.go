@@ -0,0 +1,127 @@
+package keypad
+
+import (
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// reopener keeps a keypad open until it is closed, opening it again whenever
+// it is lost and telling statusFn when it is lost and found
+type reopener struct {
+	statusFn func(online bool, err error)
+
+	mu     sync.Mutex
+	dev    io.Closer
+	closed bool
+	done   chan struct{}
+}
+
+func newReopener(statusFn func(bool, error)) reopener {
+	if statusFn == nil {
+		statusFn = func(bool, error) {}
+	}
+	return reopener{statusFn: statusFn, done: make(chan struct{})}
+}
+
+// Close stops reading, closing the keypad
+func (r *reopener) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	if r.dev != nil {
+		return r.dev.Close()
+	}
+	return nil
+}
+
+// run reads each keypad opened until it fails, waiting before opening it
+// again, up to evdevMaxRetry, until Close is called
+func (r *reopener) run(open func() (io.Closer, error), read func(dev io.Closer) error) error {
+	retry := evdevRetry
+	online, reported := false, false
+	report := func(up bool, err error) {
+		if !reported || up != online {
+			online, reported = up, true
+			r.statusFn(up, err)
+		}
+	}
+	for {
+		dev, err := open()
+		if err == nil {
+			if !r.attach(dev) {
+				dev.Close()
+				return nil
+			}
+			log.Println("KEYPAD: Keypad opened")
+			report(true, nil)
+			retry = evdevRetry
+			err = read(dev)
+			r.attach(nil)
+			dev.Close()
+		}
+		select {
+		case <-r.done:
+			return nil
+		default:
+		}
+		log.Printf("KEYPAD: Keypad unavailable, retrying in %v: %v\n", retry, err)
+		report(false, err)
+		select {
+		case <-r.done:
+			return nil
+		case <-time.After(retry):
+		}
+		retry *= 2
+		if retry > evdevMaxRetry {
+			retry = evdevMaxRetry
+		}
+	}
+}
+
+// attach keeps the open keypad so that Close can close it, reporting false if
+// the reopener is already closed
+func (r *reopener) attach(dev io.Closer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.dev = dev
+	return true
+}
+
+// RetryingSource opens a serial or TCP keypad again whenever it fails, as
+// EvdevSource does
+type RetryingSource struct {
+	reopener
+	open  func() (KeySource, error)
+	first KeySource
+}
+
+// NewRetryingSource reads keys from the source, calling open for another when
+// it fails. statusFn is told when the source is lost, and when it is opened.
+func NewRetryingSource(first KeySource, open func() (KeySource, error), statusFn func(online bool, err error)) *RetryingSource {
+	return &RetryingSource{reopener: newReopener(statusFn), open: open, first: first}
+}
+
+// Keys sends the keys from each source opened until the source is closed
+func (s *RetryingSource) Keys(keys chan<- Key) error {
+	return s.run(func() (io.Closer, error) {
+		if first := s.first; first != nil {
+			s.first = nil
+			return first, nil
+		}
+		return s.open()
+	}, func(dev io.Closer) error {
+		if err := dev.(KeySource).Keys(keys); err != nil {
+			return err
+		}
+		return io.EOF
+	})
+}
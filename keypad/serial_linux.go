@@ -0,0 +1,49 @@
+package keypad
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var bauds = map[int]uint32{
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+// SerialSource reads keys sent as text, as for TextSource, over a serial line
+type SerialSource struct {
+	TextSource
+}
+
+// OpenSerial opens the serial device raw, 8N1 at the baud rate
+func OpenSerial(device string, baud int) (*SerialSource, error) {
+	rate, ok := bauds[baud]
+	if !ok {
+		return nil, fmt.Errorf("KEYPAD: unsupported baud rate: %v", baud)
+	}
+	f, err := os.OpenFile(device, os.O_RDONLY|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	termios := syscall.Termios{
+		Cflag:  rate | syscall.CS8 | syscall.CREAD | syscall.CLOCAL,
+		Ispeed: rate,
+		Ospeed: rate,
+	}
+	// Block until at least one byte has been read
+	termios.Cc[syscall.VMIN] = 1
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		f.Close()
+		return nil, fmt.Errorf("KEYPAD: could not configure %v: %v", device, errno)
+	}
+	return &SerialSource{TextSource{r: f}}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package keypad
+
+import "errors"
+
+// SerialSource reads keys sent as text, as for TextSource, over a serial line
+type SerialSource struct {
+	TextSource
+}
+
+// OpenSerial is only supported on Linux
+func OpenSerial(device string, baud int) (*SerialSource, error) {
+	return nil, errors.New("KEYPAD: serial key source is only supported on Linux")
+}
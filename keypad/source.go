@@ -0,0 +1,212 @@
+package keypad
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Key is a key pressed on a keypad, a digit, enter or clear, or a card read
+type Key struct {
+	Digit string
+	Enter bool
+	Clear bool
+	// Backspace deletes the last digit
+	Backspace bool
+	// Card is the ID of a card, submitted as a code on its own
+	Card string
+}
+
+// KeySource is where keys are pressed, e.g. the keypad's evdev device
+type KeySource interface {
+	// Keys sends the keys pressed until the source is closed or fails
+	Keys(keys chan<- Key) error
+	Close() error
+}
+
+const defaultBaud = 9600
+
+// OpenSource opens a key source from a spec: evdev[:device], stdin,
+// serial:device[:baud], tcp:address or wiegand:chip:d0:d1, defaulting to the
+// evdev DefaultDevice. The keymap maps an evdev device's key codes, and
+// statusFn is told when an evdev, serial or TCP keypad is lost and found, see
+// OpenEvdev and NewRetryingSource.
+func OpenSource(spec string, keymap *Keymap, statusFn func(online bool, err error)) (KeySource, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "", "evdev":
+		if arg == "" {
+			arg = DefaultDevice
+		}
+		return OpenEvdev(arg, keymap, statusFn)
+	case "stdin":
+		return NewTextSource(os.Stdin), nil
+	case "serial":
+		device, rate, _ := strings.Cut(arg, ":")
+		baud := defaultBaud
+		if rate != "" {
+			var err error
+			baud, err = strconv.Atoi(rate)
+			if err != nil {
+				return nil, fmt.Errorf("KEYPAD: invalid baud rate: %q", rate)
+			}
+		}
+		first, err := OpenSerial(device, baud)
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryingSource(first, func() (KeySource, error) {
+			return OpenSerial(device, baud)
+		}, statusFn), nil
+	case "tcp":
+		first, err := ListenTCP(arg)
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryingSource(first, func() (KeySource, error) {
+			return ListenTCP(arg)
+		}, statusFn), nil
+	case "wiegand":
+		parts := strings.Split(arg, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("KEYPAD: expected wiegand:chip:d0:d1: %q", spec)
+		}
+		d0, err0 := strconv.Atoi(parts[1])
+		d1, err1 := strconv.Atoi(parts[2])
+		if err0 != nil || err1 != nil {
+			return nil, fmt.Errorf("KEYPAD: invalid Wiegand lines: %q", spec)
+		}
+		pulses, err := OpenGPIOPulses(parts[0], d0, d1)
+		if err != nil {
+			return nil, err
+		}
+		return NewWiegand(pulses), nil
+	default:
+		return nil, fmt.Errorf("KEYPAD: unknown key source: %q", spec)
+	}
+}
+
+// TextSource reads keys typed as text: digits, '#' or a new line to enter, '*'
+// to clear and backspace or delete to delete a digit. Anything else is ignored.
+type TextSource struct {
+	r io.Reader
+}
+
+// NewTextSource creates a source reading text, e.g. from a terminal or a test
+func NewTextSource(r io.Reader) *TextSource {
+	return &TextSource{r: r}
+}
+
+// Keys sends the keys read from the text until it fails, or ends with io.EOF
+func (s *TextSource) Keys(keys chan<- Key) error {
+	return readText(s.r, keys)
+}
+
+// Close closes the reader if it can be closed
+func (s *TextSource) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func readText(r io.Reader, keys chan<- Key) error {
+	buffer := make([]byte, 64)
+	for {
+		n, err := r.Read(buffer)
+		for _, b := range buffer[:n] {
+			if key, ok := textKey(b); ok {
+				keys <- key
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func textKey(b byte) (Key, bool) {
+	switch {
+	case b >= '0' && b <= '9':
+		return Key{Digit: string(b)}, true
+	case b == '#' || b == '\n' || b == '\r':
+		return Key{Enter: true}, true
+	case b == '*':
+		return Key{Clear: true}, true
+	case b == '\b' || b == 0x7f:
+		return Key{Backspace: true}, true
+	}
+	return Key{}, false
+}
+
+// TCPSource is a virtual keypad, accepting a connection that sends keys as
+// text as for TextSource. Connections are served one at a time, so that the
+// keys of two are never mixed in one code, and are not authenticated.
+type TCPSource struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conn     net.Conn
+	closed   bool
+}
+
+// ListenTCP listens for virtual keypads on the address, e.g. "127.0.0.1:7777"
+func ListenTCP(address string) (*TCPSource, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("KEYPAD: Listening for keys on %v\n", listener.Addr())
+	return &TCPSource{listener: listener}, nil
+}
+
+// Addr is the address listened on
+func (s *TCPSource) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Keys sends the keys from each connection in turn, until the source is
+// closed
+func (s *TCPSource) Keys(keys chan<- Key) error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return err
+		}
+		s.serve(conn, keys)
+	}
+}
+
+func (s *TCPSource) serve(conn net.Conn, keys chan<- Key) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	log.Printf("KEYPAD: Virtual keypad connected: %v\n", conn.RemoteAddr())
+	err := readText(conn, keys)
+	log.Printf("KEYPAD: Virtual keypad disconnected: %v, %v\n", conn.RemoteAddr(), err)
+	s.mu.Lock()
+	s.conn = nil
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// Close stops listening and disconnects the virtual keypad
+func (s *TCPSource) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	return err
+}
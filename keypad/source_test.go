@@ -0,0 +1,194 @@
+package keypad
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestTextSource(t *testing.T) {
+	keys := make(chan Key, 16)
+	err := NewTextSource(strings.NewReader("12a*3#\n")).Keys(keys)
+	if err != io.EOF {
+		t.Errorf("Expected EOF but %v\n", err)
+	}
+	close(keys)
+	var got []Key
+	for k := range keys {
+		got = append(got, k)
+	}
+	expected := []Key{{Digit: "1"}, {Digit: "2"}, {Clear: true}, {Digit: "3"}, {Enter: true}, {Enter: true}}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v but %v\n", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v but %v\n", expected[i], got[i])
+		}
+	}
+}
+
+func TestScanCodes(t *testing.T) {
+	codes := make(chan Code, 16)
+	errs := make(chan error, 1)
+	source := NewTextSource(strings.NewReader("12#9*123456"))
+	go ScanCodes(source, time.Minute, 6, func(c Code) { codes <- c }, func(string) {}, func(err error) { errs <- err })
+
+	expected := []Code{
+		{Digits: "1", Submitted: Partial},
+		{Digits: "12", Submitted: Partial},
+		{Digits: "12", Submitted: User},
+		{Digits: "9", Submitted: Partial},
+		{Digits: "1", Submitted: Partial},
+		{Digits: "12", Submitted: Partial},
+		{Digits: "123", Submitted: Partial},
+		{Digits: "1234", Submitted: Partial},
+		{Digits: "12345", Submitted: Partial},
+		{Digits: "123456", Submitted: Final},
+	}
+	for _, e := range expected {
+		select {
+		case c := <-codes:
+			if c != e {
+				t.Errorf("Expected %v but %v\n", e, c)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %v\n", e)
+		}
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("Expected the end of the source not to be a failure but %v\n", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	failed := NewTextSource(io.MultiReader(strings.NewReader("1"), iotest.ErrReader(errors.New("read failed"))))
+	go ScanCodes(failed, time.Minute, 6, func(Code) {}, func(string) {}, func(err error) { errs <- err })
+	select {
+	case err := <-errs:
+		if err == nil || err.Error() != "read failed" {
+			t.Errorf("Expected the read error but %v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the failure to be reported\n")
+	}
+}
+
+func TestRetryingSource(t *testing.T) {
+	evdevRetry, evdevMaxRetry = time.Millisecond, 4*time.Millisecond
+	defer func() { evdevRetry, evdevMaxRetry = time.Second, 30*time.Second }()
+
+	opens := 0
+	open := func() (KeySource, error) {
+		opens++
+		if opens != 2 {
+			return nil, errors.New("no such device")
+		}
+		return NewTextSource(strings.NewReader("2")), nil
+	}
+	statuses := make(chan status, 8)
+	source := NewRetryingSource(NewTextSource(strings.NewReader("1")), open, func(online bool, err error) { statuses <- status{online, err} })
+	keys := make(chan Key, 8)
+	done := make(chan error)
+	go func() { done <- source.Keys(keys) }()
+
+	for _, e := range []Key{{Digit: "1"}, {Digit: "2"}} {
+		select {
+		case k := <-keys:
+			if k != e {
+				t.Errorf("Expected %v but %v\n", e, k)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %v\n", e)
+		}
+	}
+	for _, online := range []bool{true, false, true} {
+		if s := <-statuses; s.online != online {
+			t.Errorf("Expected online %v but %v\n", online, s)
+		}
+	}
+
+	source.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error when closed but %v\n", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected Keys to return when closed\n")
+	}
+}
+
+func TestTCPSource(t *testing.T) {
+	source, err := ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected to listen: %v\n", err)
+	}
+	keys := make(chan Key, 16)
+	done := make(chan error)
+	go func() { done <- source.Keys(keys) }()
+
+	conn, err := net.Dial("tcp", source.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected to connect: %v\n", err)
+	}
+	conn.Write([]byte("42#"))
+	for _, e := range []Key{{Digit: "4"}, {Digit: "2"}, {Enter: true}} {
+		select {
+		case k := <-keys:
+			if k != e {
+				t.Errorf("Expected %v but %v\n", e, k)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %v\n", e)
+		}
+	}
+
+	// a second keypad waits for the first to disconnect
+	second, err := net.Dial("tcp", source.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected to connect: %v\n", err)
+	}
+	second.Write([]byte("9"))
+	conn.Write([]byte("7"))
+	select {
+	case k := <-keys:
+		if k != (Key{Digit: "7"}) {
+			t.Errorf("Expected only the first keypad's keys but %v\n", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected 7\n")
+	}
+	conn.Close()
+	select {
+	case k := <-keys:
+		if k != (Key{Digit: "9"}) {
+			t.Errorf("Expected the second keypad's keys but %v\n", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected 9\n")
+	}
+
+	source.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected Keys to return when closed\n")
+	}
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Expected connection to be closed\n")
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	if _, err := OpenSource("bluetooth", nil, nil); err == nil {
+		t.Errorf("Expected error for unknown source\n")
+	}
+	if _, err := OpenSource("serial:/dev/null:123", nil, nil); err == nil {
+		t.Errorf("Expected error for unsupported baud rate\n")
+	}
+}
@@ -0,0 +1,217 @@
+package keypad
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Pulse is a pulse on one of a Wiegand reader's data lines, D1 for a 1 bit and
+// D0 for a 0 bit
+type Pulse struct {
+	Bit bool
+	At  time.Time
+}
+
+// PulseSource delivers a Wiegand reader's pulses, e.g. from GPIO edge events
+type PulseSource interface {
+	// Pulses sends the pulses until the source is closed or fails
+	Pulses(pulses chan<- Pulse) error
+	Close() error
+}
+
+// WiegandFrameGap is the quiet time that ends a frame. Bits are sent every
+// 2ms or so.
+const WiegandFrameGap = 25 * time.Millisecond
+
+// wiegandCard describes a card format by where its parity bits cover: the
+// leading even parity bit covers the bits before even, and the trailing odd
+// parity bit the bits from odd
+type wiegandCard struct {
+	even int
+	odd  int
+}
+
+var wiegandCards = map[int]wiegandCard{
+	26: {even: 13, odd: 13},
+	34: {even: 17, odd: 17},
+	37: {even: 19, odd: 18},
+}
+
+// WiegandSource reads the keys and cards of a Wiegand reader. Keypads send a
+// 4-bit or 8-bit burst for each key, where 10 is '*' and 11 is '#', and cards
+// 26, 34 or 37-bit frames with parity. A card's ID is sent as Key.Card.
+type WiegandSource struct {
+	pulses PulseSource
+}
+
+// NewWiegand creates a key source reading the pulses
+func NewWiegand(pulses PulseSource) *WiegandSource {
+	return &WiegandSource{pulses: pulses}
+}
+
+// Close stops reading, closing the reader's pulses
+func (w *WiegandSource) Close() error {
+	return w.pulses.Close()
+}
+
+// Keys gathers pulses into frames, sending the key or card each decodes to.
+// Frames that fail to decode are logged and dropped.
+func (w *WiegandSource) Keys(keys chan<- Key) error {
+	pulses := make(chan Pulse)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.pulses.Pulses(pulses)
+		close(pulses)
+	}()
+
+	var frame []Pulse
+	var last time.Time
+	flush := func() {
+		if len(frame) == 0 {
+			return
+		}
+		// D0 and D1 are read separately, so order the bits by when the
+		// kernel saw them rather than when they arrived
+		sort.SliceStable(frame, func(i, j int) bool { return frame[i].At.Before(frame[j].At) })
+		bits := make([]bool, len(frame))
+		for i, p := range frame {
+			bits[i] = p.Bit
+		}
+		frame = nil
+		key, err := DecodeWiegand(bits)
+		if err != nil {
+			log.Printf("KEYPAD: Wiegand frame dropped: %v\n", err)
+		} else {
+			keys <- key
+		}
+	}
+	gap := time.NewTimer(WiegandFrameGap)
+	defer gap.Stop()
+	for {
+		select {
+		case p, ok := <-pulses:
+			if !ok {
+				flush()
+				err := <-done
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			if len(frame) > 0 && p.At.Sub(last) >= WiegandFrameGap {
+				flush()
+			}
+			frame = append(frame, p)
+			if p.At.After(last) {
+				last = p.At
+			}
+			if !gap.Stop() {
+				select {
+				case <-gap.C:
+				default:
+				}
+			}
+			gap.Reset(WiegandFrameGap)
+		case <-gap.C:
+			flush()
+		}
+	}
+}
+
+// DecodeWiegand decodes a frame to a key, or to the ID of a card
+func DecodeWiegand(bits []bool) (Key, error) {
+	switch len(bits) {
+	case 4:
+		return wiegandKey(wiegandValue(bits))
+	case 8:
+		high, low := wiegandValue(bits[:4]), wiegandValue(bits[4:])
+		if high != ^low&0xf {
+			return Key{}, fmt.Errorf("8-bit key %04b%04b is not complemented", high, low)
+		}
+		return wiegandKey(low)
+	}
+	card, ok := wiegandCards[len(bits)]
+	if !ok {
+		return Key{}, fmt.Errorf("unknown %v-bit frame", len(bits))
+	}
+	if ones(bits[:card.even])%2 != 0 {
+		return Key{}, fmt.Errorf("%v-bit frame failed even parity", len(bits))
+	}
+	if ones(bits[card.odd:])%2 != 1 {
+		return Key{}, fmt.Errorf("%v-bit frame failed odd parity", len(bits))
+	}
+	return Key{Card: strconv.FormatUint(wiegandValue(bits[1:len(bits)-1]), 10)}, nil
+}
+
+func wiegandKey(value uint64) (Key, error) {
+	switch {
+	case value <= 9:
+		return Key{Digit: strconv.FormatUint(value, 10)}, nil
+	case value == 10:
+		return Key{Clear: true}, nil
+	case value == 11:
+		return Key{Enter: true}, nil
+	}
+	return Key{}, fmt.Errorf("unknown key %v", value)
+}
+
+func wiegandValue(bits []bool) uint64 {
+	var value uint64
+	for _, bit := range bits {
+		value <<= 1
+		if bit {
+			value |= 1
+		}
+	}
+	return value
+}
+
+func ones(bits []bool) int {
+	n := 0
+	for _, bit := range bits {
+		if bit {
+			n++
+		}
+	}
+	return n
+}
+
+// RecordedPulses replays a recorded bit stream, e.g. in tests
+type RecordedPulses []Pulse
+
+// ParsePulses reads a recording of frames of '0' and '1' bits, one frame per
+// line, as pulses 2ms apart with a gap between frames
+func ParsePulses(recording string, start time.Time) (RecordedPulses, error) {
+	var pulses RecordedPulses
+	at := start
+	for _, c := range recording {
+		switch c {
+		case '0', '1':
+			pulses = append(pulses, Pulse{Bit: c == '1', At: at})
+			at = at.Add(2 * time.Millisecond)
+		case '\n':
+			at = at.Add(WiegandFrameGap)
+		case ' ', '\t', '\r':
+		default:
+			return nil, fmt.Errorf("KEYPAD: invalid pulse %q", c)
+		}
+	}
+	return pulses, nil
+}
+
+// Pulses sends the recorded pulses, then io.EOF
+func (r RecordedPulses) Pulses(pulses chan<- Pulse) error {
+	for _, p := range r {
+		pulses <- p
+	}
+	return io.EOF
+}
+
+// Close does nothing, as a recording holds nothing open
+func (r RecordedPulses) Close() error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package keypad
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	gpioHandleRequestInput  = 1 << 0
+	gpioEventRequestFalling = 1 << 1
+	gpioEventDataSize       = 16
+	gpioConsumer            = "parceldrop-wiegand"
+)
+
+// gpioEventRequest is the kernel's struct gpioevent_request, from the v1 GPIO
+// character device ABI in linux/gpio.h
+type gpioEventRequest struct {
+	LineOffset    uint32
+	HandleFlags   uint32
+	EventFlags    uint32
+	ConsumerLabel [32]byte
+	Fd            int32
+}
+
+// gpioGetLineEventIoctl is _IOWR(0xB4, 0x04, struct gpioevent_request)
+var gpioGetLineEventIoctl = 3<<30 | unsafe.Sizeof(gpioEventRequest{})<<16 | 0xB4<<8 | 0x04
+
+// GPIOPulses reads a Wiegand reader's pulses, the falling edges of its D0 and
+// D1 lines, from the Linux GPIO character device
+type GPIOPulses struct {
+	d0 *os.File
+	d1 *os.File
+}
+
+// OpenGPIOPulses requests edge events for the D0 and D1 lines of the chip
+func OpenGPIOPulses(chip string, d0, d1 int) (*GPIOPulses, error) {
+	f, err := os.OpenFile(chip, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("KEYPAD: open GPIO chip: %s", err)
+	}
+	defer f.Close()
+	g := &GPIOPulses{}
+	if g.d0, err = requestEvents(f, d0); err != nil {
+		return nil, err
+	}
+	if g.d1, err = requestEvents(f, d1); err != nil {
+		g.d0.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+func requestEvents(chip *os.File, line int) (*os.File, error) {
+	req := gpioEventRequest{LineOffset: uint32(line), HandleFlags: gpioHandleRequestInput, EventFlags: gpioEventRequestFalling}
+	copy(req.ConsumerLabel[:], gpioConsumer)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, chip.Fd(), gpioGetLineEventIoctl, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return nil, fmt.Errorf("KEYPAD: request GPIO line %v events: %s", line, errno)
+	}
+	return os.NewFile(uintptr(req.Fd), fmt.Sprintf("%s:%d", chip.Name(), line)), nil
+}
+
+// Pulses sends the edges of both lines, stamped by the kernel, until either
+// line fails. Both lines are closed before it returns, so that neither is left
+// sending.
+func (g *GPIOPulses) Pulses(pulses chan<- Pulse) error {
+	errs := make(chan error, 2)
+	for _, line := range []*os.File{g.d0, g.d1} {
+		go func(line *os.File, bit bool) {
+			event := make([]byte, gpioEventDataSize)
+			for {
+				if _, err := line.Read(event); err != nil {
+					errs <- err
+					return
+				}
+				pulses <- Pulse{Bit: bit, At: time.Unix(0, int64(binary.LittleEndian.Uint64(event)))}
+			}
+		}(line, line == g.d1)
+	}
+	err := <-errs
+	g.Close()
+	<-errs
+	return err
+}
+
+// Close releases both lines, ending Pulses
+func (g *GPIOPulses) Close() error {
+	g.d0.Close()
+	return g.d1.Close()
+}
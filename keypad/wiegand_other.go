@@ -0,0 +1,23 @@
+//go:build !linux
+
+package keypad
+
+import "errors"
+
+// GPIOPulses reads a Wiegand reader's pulses from GPIO edge events
+type GPIOPulses struct{}
+
+// OpenGPIOPulses is only supported on Linux
+func OpenGPIOPulses(chip string, d0, d1 int) (*GPIOPulses, error) {
+	return nil, errors.New("KEYPAD: Wiegand GPIO is only supported on Linux")
+}
+
+// Pulses is only supported on Linux
+func (g *GPIOPulses) Pulses(pulses chan<- Pulse) error {
+	return errors.New("KEYPAD: Wiegand GPIO is only supported on Linux")
+}
+
+// Close does nothing, as nothing can be opened
+func (g *GPIOPulses) Close() error {
+	return nil
+}
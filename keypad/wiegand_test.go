@@ -0,0 +1,135 @@
+package keypad
+
+import (
+	"testing"
+	"time"
+)
+
+func decode(t *testing.T, recording string) []Key {
+	pulses, err := ParsePulses(recording, time.Unix(1510556400, 0))
+	if err != nil {
+		t.Fatalf("Expected recording to parse: %v\n", err)
+	}
+	keys := make(chan Key, 16)
+	if err := NewWiegand(pulses).Keys(keys); err != nil {
+		t.Errorf("Expected no error at the end of the recording but %v\n", err)
+	}
+	close(keys)
+	var got []Key
+	for k := range keys {
+		got = append(got, k)
+	}
+	return got
+}
+
+func expectKeys(t *testing.T, expected, got []Key) {
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v but %v\n", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v but %v\n", expected[i], got[i])
+		}
+	}
+}
+
+func TestWiegand4Bit(t *testing.T) {
+	got := decode(t, "0001\n1001\n0000\n1010\n1011\n1100\n")
+	expectKeys(t, []Key{{Digit: "1"}, {Digit: "9"}, {Digit: "0"}, {Clear: true}, {Enter: true}}, got)
+}
+
+func TestWiegand8Bit(t *testing.T) {
+	// Each key is sent with its complement in the high nibble
+	got := decode(t, "11100001\n01111000\n01001011\n00110101\n")
+	expectKeys(t, []Key{{Digit: "1"}, {Digit: "8"}, {Enter: true}}, got)
+}
+
+func TestWiegandCards(t *testing.T) {
+	got := decode(t, `10111101100010001110101110
+1110111101010110110111110111011110
+1000001001101001000011011101110101010
+`)
+	expectKeys(t, []Key{{Card: "8065495"}, {Card: "3735928559"}, {Card: "647028181"}}, got)
+}
+
+func TestWiegandParity(t *testing.T) {
+	// The 26-bit card with a bit flipped in each half, and a bad 37-bit frame
+	got := decode(t, `10111101101010001110101110
+10111101100010001110111110
+1000001001101001000011011101110101011
+10111101100010001110101110
+`)
+	expectKeys(t, []Key{{Card: "8065495"}}, got)
+
+	if _, err := DecodeWiegand(make([]bool, 30)); err == nil {
+		t.Errorf("Expected error for unknown frame length\n")
+	}
+}
+
+func TestWiegandFrameGap(t *testing.T) {
+	// Keys sent back to back are only split by the gap between them
+	start := time.Unix(1510556400, 0)
+	var pulses RecordedPulses
+	for i, bit := range []bool{false, false, true, false, false, true, false, true} {
+		at := start.Add(time.Duration(i) * 2 * time.Millisecond)
+		if i >= 4 {
+			at = at.Add(WiegandFrameGap)
+		}
+		pulses = append(pulses, Pulse{Bit: bit, At: at})
+	}
+	keys := make(chan Key, 4)
+	NewWiegand(pulses).Keys(keys)
+	close(keys)
+	var got []Key
+	for k := range keys {
+		got = append(got, k)
+	}
+	expectKeys(t, []Key{{Digit: "2"}, {Digit: "5"}}, got)
+}
+
+func TestWiegandCodes(t *testing.T) {
+	pulses, _ := ParsePulses("0100\n0010\n1011\n10111101100010001110101110\n", time.Unix(1510556400, 0))
+	codes := make(chan Code, 8)
+	go ScanCodes(NewWiegand(pulses), time.Minute, 6, func(c Code) { codes <- c }, func(string) {}, func(error) {})
+
+	expected := []Code{
+		{Digits: "4", Submitted: Partial},
+		{Digits: "42", Submitted: Partial},
+		{Digits: "42", Submitted: User},
+		{Digits: "8065495", Submitted: Card},
+	}
+	for _, e := range expected {
+		select {
+		case c := <-codes:
+			if c != e {
+				t.Errorf("Expected %v but %v\n", e, c)
+			}
+			if c.Complete() != (e.Submitted != Partial) {
+				t.Errorf("Expected %v to be complete\n", c)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %v\n", e)
+		}
+	}
+}
+
+func TestWiegandOutOfOrder(t *testing.T) {
+	// The 26-bit card with its D0 pulses read before its D1 pulses
+	pulses, _ := ParsePulses("10111101100010001110101110", time.Unix(1510556400, 0))
+	var reordered RecordedPulses
+	for _, bit := range []bool{false, true} {
+		for _, p := range pulses {
+			if p.Bit == bit {
+				reordered = append(reordered, p)
+			}
+		}
+	}
+	keys := make(chan Key, 4)
+	NewWiegand(reordered).Keys(keys)
+	close(keys)
+	var got []Key
+	for k := range keys {
+		got = append(got, k)
+	}
+	expectKeys(t, []Key{{Card: "8065495"}}, got)
+}
@@ -0,0 +1,136 @@
+// Package lockout guards the keypad against codes being guessed, locking it
+// for escalating periods once too many invalid codes are entered
+package lockout
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const stateKey = "lockout"
+
+// Config sets when the keypad locks and for how long
+type Config struct {
+	// Threshold is the number of failures within Window that locks the keypad
+	Threshold int
+	Window    time.Duration
+	// Base is the first lockout period, doubling with each further lockout
+	// up to Max. The escalation is reset by a valid code, or once the keypad
+	// has gone unlocked for Max.
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Persister saves the lockout state across restarts
+type Persister interface {
+	LoadState(key string, v interface{}) (bool, error)
+	SaveState(key string, v interface{}) error
+}
+
+// AlertFn is called once when the keypad is locked
+type AlertFn func(until time.Time, failures int)
+
+// Lockout tracks invalid codes over a sliding window
+type Lockout struct {
+	mu      sync.Mutex
+	config  Config
+	persist Persister
+	alertFn AlertFn
+	state   state
+}
+
+type state struct {
+	Failures []time.Time
+	Until    time.Time
+	// Level is the number of lockouts since the escalation was last reset
+	Level int
+}
+
+// New creates a Lockout, restoring any state saved before a restart
+func New(config Config, persist Persister, alertFn AlertFn) *Lockout {
+	l := &Lockout{
+		config:  config,
+		persist: persist,
+		alertFn: alertFn,
+	}
+	if _, err := persist.LoadState(stateKey, &l.state); err != nil {
+		log.Printf("LOCKOUT: Could not load state: %v\n", err)
+	}
+	return l
+}
+
+// Locked reports whether the keypad is locked, and until when
+func (l *Lockout) Locked(now time.Time) (bool, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Before(l.state.Until), l.state.Until
+}
+
+// Fail records an invalid code, returning true if it locked the keypad.
+// Codes entered while locked are not counted.
+func (l *Lockout) Fail(now time.Time) (bool, time.Time) {
+	l.mu.Lock()
+	if now.Before(l.state.Until) {
+		until := l.state.Until
+		l.mu.Unlock()
+		return true, until
+	}
+	if l.state.Level > 0 && now.Sub(l.state.Until) >= l.config.Max {
+		l.state.Level = 0
+	}
+
+	recent := l.state.Failures[:0]
+	for _, failure := range l.state.Failures {
+		if now.Sub(failure) < l.config.Window {
+			recent = append(recent, failure)
+		}
+	}
+	l.state.Failures = append(recent, now)
+
+	locked := len(l.state.Failures) >= l.config.Threshold
+	failures := len(l.state.Failures)
+	if locked {
+		l.state.Until = now.Add(l.backoff())
+		l.state.Level++
+		l.state.Failures = nil
+		log.Printf("LOCKOUT: Locked after %v failures until %v\n", failures, l.state.Until)
+	}
+	l.save()
+	until := l.state.Until
+	l.mu.Unlock()
+
+	if locked {
+		l.alertFn(until, failures)
+	}
+	return locked, until
+}
+
+// Succeed records a valid code, clearing the failures, any lockout and the
+// escalation
+func (l *Lockout) Succeed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.state.Failures) == 0 && l.state.Level == 0 && l.state.Until.IsZero() {
+		return
+	}
+	l.state = state{}
+	l.save()
+}
+
+func (l *Lockout) backoff() time.Duration {
+	period := l.config.Base
+	for i := 0; i < l.state.Level && period < l.config.Max; i++ {
+		period *= 2
+	}
+	if period > l.config.Max {
+		period = l.config.Max
+	}
+	return period
+}
+
+func (l *Lockout) save() {
+	if err := l.persist.SaveState(stateKey, l.state); err != nil {
+		log.Printf("LOCKOUT: Could not save state: %v\n", err)
+	}
+}
@@ -0,0 +1,121 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teabot/parceldrop/codebook"
+)
+
+var config = Config{
+	Threshold: 3,
+	Window:    time.Minute,
+	Base:      time.Minute,
+	Max:       4 * time.Minute,
+}
+
+func newBook() *codebook.Codebook {
+	return codebook.New(codebook.NewMemoryStore(), "123456", "999999", 0, 0)
+}
+
+func TestLockAfterThreshold(t *testing.T) {
+	alerts := 0
+	l := New(config, newBook(), func(time.Time, int) { alerts++ })
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+
+	if locked, _ := l.Fail(now); locked {
+		t.Errorf("Expected not locked\n")
+	}
+	l.Fail(now.Add(10 * time.Second))
+	locked, until := l.Fail(now.Add(20 * time.Second))
+	if !locked || !until.Equal(now.Add(80*time.Second)) {
+		t.Errorf("Expected locked for a minute, got %v, %v\n", locked, until)
+	}
+	if locked, _ := l.Locked(now.Add(30 * time.Second)); !locked {
+		t.Errorf("Expected locked\n")
+	}
+	l.Fail(now.Add(40 * time.Second))
+	if alerts != 1 {
+		t.Errorf("Expected a single alert, got %v\n", alerts)
+	}
+	if locked, _ := l.Locked(now.Add(80 * time.Second)); locked {
+		t.Errorf("Expected unlocked\n")
+	}
+}
+
+func TestAlertReadsLockout(t *testing.T) {
+	var l *Lockout
+	alerted := make(chan bool, 1)
+	l = New(config, newBook(), func(until time.Time, failures int) {
+		locked, _ := l.Locked(until.Add(-time.Second))
+		alerted <- locked
+	})
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < config.Threshold; i++ {
+			l.Fail(now)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the alert not to block Fail\n")
+	}
+	if locked := <-alerted; !locked {
+		t.Errorf("Expected the alert to see the keypad locked\n")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l := New(config, newBook(), func(time.Time, int) {})
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+
+	l.Fail(now)
+	l.Fail(now.Add(40 * time.Second))
+	if locked, _ := l.Fail(now.Add(70 * time.Second)); locked {
+		t.Errorf("Expected first failure to have left the window\n")
+	}
+	if locked, _ := l.Fail(now.Add(80 * time.Second)); !locked {
+		t.Errorf("Expected locked\n")
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	l := New(config, newBook(), func(time.Time, int) {})
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for i, period := range expected {
+		l.Fail(now)
+		l.Fail(now)
+		_, until := l.Fail(now)
+		if until.Sub(now) != period {
+			t.Errorf("Expected lockout %v of %v, got %v\n", i, period, until.Sub(now))
+		}
+		now = until
+	}
+
+	l.Succeed()
+	l.Fail(now)
+	l.Fail(now)
+	if _, until := l.Fail(now); until.Sub(now) != time.Minute {
+		t.Errorf("Expected escalation reset, got %v\n", until.Sub(now))
+	}
+}
+
+func TestStateRestored(t *testing.T) {
+	book := newBook()
+	now := time.Date(2017, 11, 9, 11, 10, 0, 0, time.UTC)
+	l := New(config, book, func(time.Time, int) {})
+	l.Fail(now)
+	l.Fail(now)
+	l.Fail(now)
+
+	restarted := New(config, book, func(time.Time, int) {})
+	if locked, _ := restarted.Locked(now.Add(time.Second)); !locked {
+		t.Errorf("Expected lockout to survive restart\n")
+	}
+}
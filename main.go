@@ -20,7 +20,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -30,6 +32,7 @@ import (
 
 	"github.com/teabot/parceldrop/door"
 	"github.com/teabot/parceldrop/keypad"
+	"github.com/teabot/parceldrop/lockout"
 	"github.com/teabot/parceldrop/sms"
 	"github.com/teabot/parceldrop/totp"
 )
@@ -39,31 +42,134 @@ const (
 	doorOpenDuration       = 60 * time.Second
 	resetToIdleDuration    = 3 * time.Second
 	resetCodeInputDuration = 5 * time.Second
+	abandonCodeDuration    = 15 * time.Second
 
 	maxCodeLength = 6
 	defaultCode   = "1234"
+
+	defaultAuditRetention = 90 * 24 * time.Hour
+	auditPruneInterval    = 24 * time.Hour
+
+	defaultLockoutThreshold = 5
+	defaultLockoutWindow    = 10 * time.Minute
+	defaultLockoutBase      = time.Minute
+	defaultLockoutMax       = time.Hour
+
+	latchFaultDisplay = 10 * time.Minute
 )
 
-var timer *time.Timer
+var controller *door.Controller
+var latchMonitor *door.LatchMonitor
+var book *codebook.Codebook
+var guard *lockout.Lockout
+
+// reservation is the use of a code held until the door is seen to open
+var reservation *codebook.Reservation
+var reservationMu sync.Mutex
 
 func main() {
-	var gracefulStop = make(chan os.Signal)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	var gracefulStop = make(chan os.Signal, 1)
 	signal.Notify(gracefulStop, syscall.SIGTERM)
 	signal.Notify(gracefulStop, syscall.SIGINT)
 
-	start, err := time.ParseDuration(os.Getenv("DAY_START"))
+	var err error
+	start, end := dayWindow()
+
+	retention := durationEnv("AUDIT_RETENTION", defaultAuditRetention)
+
+	store, err := codebook.OpenBoltStore(os.Getenv("CODEBOOK_PATH"))
 	if err != nil {
-		log.Fatalf("Invalid day start: %v\n", os.Getenv("DAY_START"))
+		log.Fatalf("Could not open codebook: %v\n", err)
 	}
-	end, err := time.ParseDuration(os.Getenv("DAY_END"))
+	book = codebook.New(store, os.Getenv("ADMIN_CODE"), defaultCode, start, end)
+	book.PruneAuditEvery(retention, auditPruneInterval)
+	guard = lockout.New(lockout.Config{
+		Threshold: countEnv("LOCKOUT_THRESHOLD", defaultLockoutThreshold),
+		Window:    durationEnv("LOCKOUT_WINDOW", defaultLockoutWindow),
+		Base:      durationEnv("LOCKOUT_BASE", defaultLockoutBase),
+		Max:       durationEnv("LOCKOUT_MAX", defaultLockoutMax),
+	}, book, lockoutAlert)
+	sms.Initialise(strings.Split(os.Getenv("SMS_DESTINATIONS"), ","))
+	sms.InitialiseAlarm(destinations(os.Getenv("ALARM_DESTINATIONS")))
+	driver, err := door.OpenDriver(os.Getenv("DOOR_DRIVER"), os.Getenv("DOOR_GPIO_CHIP"))
 	if err != nil {
-		log.Fatalf("Invalid day end: %v\n", os.Getenv("DAY_END"))
+		log.Fatalf("Could not open door driver: %v\n", err)
 	}
-
-	codebook.Initialise(os.Getenv("CODEBOOK_PATH"), os.Getenv("ADMIN_CODE"), defaultCode, start, end)
-	sms.Initialise(strings.Split(os.Getenv("SMS_DESTINATIONS"), ","))
-	door.Initialise(overrideOpen)
-	control.InitialiseSqs(os.Getenv("AWS_SQS_QUEUE"), overrideOpen)
+	pins, err := door.ParsePins(os.Getenv("DOOR_PINS"))
+	if err != nil {
+		log.Fatalf("Invalid door pins: %v\n", err)
+	}
+	if sim, ok := driver.(*door.Simulator); ok && os.Getenv("DOOR_SIM_CONTROL") != "" {
+		// Opened read-write, so that the FIFO stays open between writers
+		control, err := os.OpenFile(os.Getenv("DOOR_SIM_CONTROL"), os.O_RDWR, 0)
+		if err != nil {
+			log.Fatalf("Could not open simulator control: %v\n", err)
+		}
+		go sim.Control(control, pins)
+	}
+	debounce := door.Debounce{
+		Interval: durationEnv("DOOR_SAMPLE_INTERVAL", door.DefaultDebounce.Interval),
+		Contact:  durationEnv("DOOR_DEBOUNCE_CONTACT", door.DefaultDebounce.Contact),
+		Override: durationEnv("DOOR_DEBOUNCE_OVERRIDE", door.DefaultDebounce.Override),
+	}
+	latchProfile := door.DefaultLatch
+	if os.Getenv("DOOR_LATCH") != "" {
+		latchProfile, err = door.ParseLatch(os.Getenv("DOOR_LATCH"))
+		if err != nil {
+			log.Fatalf("Invalid door latch: %v\n", err)
+		}
+	}
+	if err = door.Initialise(driver, pins, debounce, latchProfile); err != nil {
+		log.Fatalf("Could not initialise door: %v\n", err)
+	}
+	button := door.NewButton(door.SystemClock{}, door.ButtonConfig{
+		LongPress:  durationEnv("OVERRIDE_LONG_PRESS", door.DefaultButton.LongPress),
+		HoldOpen:   durationEnv("OVERRIDE_HOLD_OPEN", door.DefaultButton.HoldOpen),
+		Cooldown:   durationEnv("OVERRIDE_COOLDOWN", door.DefaultButton.Cooldown),
+		MaxPresses: countEnv("OVERRIDE_MAX_PRESSES", door.DefaultButton.MaxPresses),
+		Window:     durationEnv("OVERRIDE_WINDOW", door.DefaultButton.Window),
+		Lockout:    durationEnv("OVERRIDE_LOCKOUT", door.DefaultButton.Lockout),
+	}, door.ButtonHandlers{
+		Press:   func() { overrideOpen("button") },
+		Hold:    func(d time.Duration) { holdOpen("button", d) },
+		Lockout: sms.SendButtonLockout,
+	})
+	go button.Watch(door.Subscribe())
+	controller = door.NewController(door.Board{}, door.SystemClock{}, door.Timings{
+		OpenWait: openWaitDuration,
+		HeldOpen: doorOpenDuration,
+		Reject:   resetToIdleDuration,
+		Verify:   abandonCodeDuration,
+	})
+	controller.Observe(doorChanged)
+	latchMonitor = door.MonitorLatch(door.SystemClock{}, door.LatchCheck{
+		Settle:     durationEnv("LATCH_SETTLE", door.DefaultLatchCheck.Settle),
+		Retry:      durationEnv("LATCH_RETRY", door.DefaultLatchCheck.Retry),
+		Retries:    countEnv("LATCH_RETRIES", door.DefaultLatchCheck.Retries),
+		FaultAfter: countEnv("LATCH_FAULT_AFTER", door.DefaultLatchCheck.FaultAfter),
+	}, latchFault)
+	controller.Observe(latchMonitor.Observe)
+	go controller.Run()
+	go watchContact(door.Subscribe())
+	tamper := door.DefaultTamper
+	tamper.Confirm = durationEnv("TAMPER_CONFIRM", tamper.Confirm)
+	tamper.Alarm = durationEnv("TAMPER_ALARM", tamper.Alarm)
+	if os.Getenv("TAMPER_PATTERN") != "" {
+		tamper.Pattern, err = door.ParsePattern(os.Getenv("TAMPER_PATTERN"))
+		if err != nil {
+			log.Fatalf("Invalid tamper pattern: %v\n", err)
+		}
+	}
+	monitor := door.NewTamperMonitor(door.SystemClock{}, tamper, door.Indicators(), controller.Phase, forcedOpen)
+	go monitor.Watch(door.Subscribe())
+	if locked, until := guard.Locked(time.Now()); locked {
+		door.Indicators().Show(door.LockoutLayer, door.StatusPriority, door.LockoutPattern, time.Until(until))
+	}
+	control.InitialiseSqs(os.Getenv("AWS_SQS_QUEUE"), os.Getenv("AWS_SQS_ALARM_QUEUE"), book, overrideOpen, controlOnline)
 
 	period, err := time.ParseDuration(os.Getenv("TOTP_PERIOD"))
 	if err != nil {
@@ -74,46 +180,63 @@ func main() {
 		period,
 	)
 
-	door.CheckSunRise(
-		os.Getenv("LATITUDE"),
-		os.Getenv("LONGITUDE"),
-		start,
-		end,
-		door.SetDarkOutside)
+	door.CheckSunRise(sky(start, end), door.SetDarkOutside)
 
 	go func() {
 		sig := <-gracefulStop
 		fmt.Printf("caught sig: %+v", sig)
 		fmt.Println("Wait for 2 second to finish processing")
-		codebook.CloseStore()
+		book.Close()
+		door.Close()
 		time.Sleep(2 * time.Second)
 		os.Exit(0)
 	}()
 
 	codeFn := func(code keypad.Code) {
-		if door.Locked() {
-			unscheduleAnyEvents()
-			door.Wait()
+		if controller.Verify() {
 			log.Printf("MAIN: Code: %v, Submitted: %v\n", code.Digits, code.Submitted)
-			if totp.Validate(code.Digits, time.Now()) {
+			now := time.Now()
+			if locked, until := guard.Locked(now); locked && !book.IsAdmin(code.Digits) {
+				if code.Complete() {
+					book.Audit(now, codebook.CodeAudit, "", codebook.Denied, "locked_out")
+					lockedOut(code.Digits, until)
+				}
+			} else if totp.Validate(code.Digits, now) {
 				log.Printf("MAIN: OTP\n")
-				validCode(code.Digits, "OTP", false)
+				guard.Succeed()
+				door.Indicators().Clear(door.LockoutLayer)
+				if validCode(code.Digits, codebook.Result{Valid: true, Name: "OTP", Notify: codebook.NotifyNormal}) {
+					book.Audit(now, codebook.TotpAudit, "OTP", codebook.Granted, "")
+				}
 			} else if code.Digits == "111110" {
 				door.LightsOff()
+				controller.Cancel()
 				log.Printf("MAIN: Lights override off\n")
 			} else if code.Digits == "111111" {
 				door.LightsOn()
+				controller.Cancel()
 				log.Printf("MAIN: Lights override on\n")
 			} else {
-				valid, silent, name := codebook.Check(code.Digits, time.Now())
-				if valid {
-					validCode(code.Digits, name, silent)
+				result := book.Check(code.Digits, now)
+				if result.Valid {
+					guard.Succeed()
+					door.Indicators().Clear(door.LockoutLayer)
+					if validCode(code.Digits, result) {
+						book.Audit(now, codebook.CodeAudit, result.Name, codebook.Granted, string(code.Submitted))
+					}
 				} else {
-					if code.Submitted == keypad.Final || code.Submitted == keypad.User {
-						invalidCode(code.Digits)
+					if code.Complete() {
+						book.Audit(now, codebook.CodeAudit, result.Name, codebook.Denied, string(result.Reason))
+						if locked, until := guard.Fail(now); locked {
+							lockedOut(code.Digits, until)
+						} else {
+							invalidCode(code.Digits, result)
+						}
 					}
 				}
 			}
+		} else {
+			latchMonitor.KeypadActivity()
 		}
 	}
 
@@ -126,122 +249,285 @@ func main() {
 	}
 
 	errorFn := func(e error) {
-		panic(e)
+		keypadStatus(false, e)
+	}
+
+	keymap, err := keypad.LoadKeymap(os.Getenv("KEYPAD_KEYMAP"))
+	if err != nil {
+		log.Fatalf("Could not load keymap: %v\n", err)
+	}
+	source, err := keypad.OpenSource(os.Getenv("KEYPAD_SOURCE"), keymap, keypadStatus)
+	if err != nil {
+		log.Fatalf("Could not open keypad: %v\n", err)
+	}
+	keypad.ScanCodes(source, resetCodeInputDuration, maxCodeLength, codeFn, keyTimeoutFn, errorFn)
+}
+
+func dayWindow() (time.Duration, time.Duration) {
+	start, err := time.ParseDuration(os.Getenv("DAY_START"))
+	if err != nil {
+		log.Fatalf("Invalid day start: %v\n", os.Getenv("DAY_START"))
+	}
+	end, err := time.ParseDuration(os.Getenv("DAY_END"))
+	if err != nil {
+		log.Fatalf("Invalid day end: %v\n", os.Getenv("DAY_END"))
+	}
+	return start, end
+}
+
+// sky configures when it is dark outside from the environment
+func sky(start, end time.Duration) door.Sky {
+	lat, err := strconv.ParseFloat(os.Getenv("LATITUDE"), 64)
+	if err != nil {
+		log.Fatalf("Invalid latitude: %v\n", os.Getenv("LATITUDE"))
+	}
+	long, err := strconv.ParseFloat(os.Getenv("LONGITUDE"), 64)
+	if err != nil {
+		log.Fatalf("Invalid longitude: %v\n", os.Getenv("LONGITUDE"))
+	}
+	location, err := time.LoadLocation(os.Getenv("SUN_TIMEZONE"))
+	if err != nil {
+		log.Fatalf("Invalid timezone: %v\n", os.Getenv("SUN_TIMEZONE"))
+	}
+	if os.Getenv("SUN_TIMEZONE") == "" {
+		location = time.Local
+	}
+	twilight, err := door.ParseTwilight(os.Getenv("SUN_TWILIGHT"))
+	if err != nil {
+		log.Fatalf("Invalid twilight: %v\n", err)
+	}
+	return door.Sky{
+		Latitude:      lat,
+		Longitude:     long,
+		Location:      location,
+		Twilight:      twilight,
+		SunriseOffset: durationEnv("SUN_SUNRISE_OFFSET", 0),
+		SunsetOffset:  durationEnv("SUN_SUNSET_OFFSET", 0),
+		DayStart:      start,
+		DayEnd:        end,
 	}
+}
 
-	keypad.ScanCodes(keypad.DefaultDevice, resetCodeInputDuration, maxCodeLength, codeFn, keyTimeoutFn, errorFn)
+// durationEnv parses an optional duration from the environment
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	if os.Getenv(name) == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		log.Fatalf("Invalid %v: %v\n", name, os.Getenv(name))
+	}
+	return d
 }
 
-func validCode(digits, name string, silent bool) {
+// countEnv parses an optional positive count from the environment
+func countEnv(name string, fallback int) int {
+	if os.Getenv(name) == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid %v: %v\n", name, os.Getenv(name))
+	}
+	return n
+}
+
+// validCode unlocks the door for an accepted code, reporting false if the
+// controller had already moved on, e.g. to an override, and the door was not
+// unlocked. The code's reserved use is then released, though a duress alarm
+// is still raised.
+func validCode(digits string, result codebook.Result) bool {
+	holdReservation(result.Reservation)
+	if !controller.Grant() {
+		log.Printf("MAIN: Code accepted but no longer verifying: %v\n", digits)
+		settleReservation(false)
+		if result.Alarm {
+			raiseAlarm(result.Name)
+		}
+		return false
+	}
 	log.Printf("MAIN: Unlocked with code: %v\n", digits)
-	door.Unlock()
-	if !silent {
-		sms.SendCorrectCode(digits, name)
+	if result.Expiring {
+		door.Indicators().Show(door.FeedbackLayer, door.FeedbackPriority, door.ExpiringPattern, 0)
+	}
+	if result.Alarm {
+		raiseAlarm(result.Name)
+	}
+	if result.Notify != codebook.NotifySilent {
+		sms.SendCorrectCode(digits, result.Name)
 	} else {
 		log.Printf("MAIN: SMS silenced for code: %v\n", digits)
 	}
-	scheduleEvent(waitForDoorToBeOpened())
+	return true
+}
+
+// raiseAlarm alerts the alarm destinations to a duress code. The door behaves
+// exactly as for any other code, and nothing is logged.
+func raiseAlarm(name string) {
+	sms.SendAlarm(name)
+	control.SendAlarm("duress", name, time.Now())
+}
+
+// destinations splits a comma separated list, ignoring empty entries
+func destinations(list string) []string {
+	split := []string{}
+	for _, d := range strings.Split(list, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			split = append(split, d)
+		}
+	}
+	return split
 }
 
 func overrideOpen(overrideType string) {
-	scheduleEvent(waitForDoorToBeOpened())
+	if !controller.Override(overrideType) {
+		log.Printf("MAIN: Override ignored, door open: %v\n", overrideType)
+		return
+	}
 	log.Printf("MAIN: Unlocked with override: %v\n", overrideType)
-	door.Unlock()
+	book.Audit(time.Now(), codebook.OverrideAudit, overrideType, codebook.Granted, "")
 	sms.SendOverrideOpen(overrideType)
 }
 
-func invalidCode(digits string) {
-	scheduleEvent(resetToIdle())
-	log.Printf("MAIN: Invalid code: %v\n", digits)
-	door.Reject()
-	sms.SendInvalidCode(digits)
+// holdOpen keeps the door unlocked for the duration
+func holdOpen(overrideType string, d time.Duration) {
+	if !controller.Hold(overrideType, d) {
+		return
+	}
+	log.Printf("MAIN: Held open with override: %v, for %v\n", overrideType, d)
+	book.Audit(time.Now(), codebook.OverrideAudit, overrideType, codebook.Granted, "hold "+d.String())
+	sms.SendOverrideHold(overrideType, d)
 }
 
-func scheduleEvent(nextTimer *time.Timer) {
-	unscheduleAnyEvents()
-	timer = nextTimer
+func invalidCode(digits string, result codebook.Result) {
+	log.Printf("MAIN: Invalid code: %v, %v\n", digits, result.Reason)
+	controller.Deny()
+	if result.Name != "" {
+		sms.SendDeniedCode(digits, result.Name, result.Reason.Describe())
+	} else {
+		sms.SendInvalidCode(digits)
+	}
 }
 
-func unscheduleAnyEvents() {
-	if timer != nil {
-		timer.Stop()
-		timer = nil
-		log.Println("MAIN: Unscheduled timer")
-	}
+// lockedOut rejects a code without notifying, a single alert having been sent
+// when the keypad locked
+func lockedOut(digits string, until time.Time) {
+	log.Printf("MAIN: Locked out code: %v, until %v\n", digits, until)
+	controller.Deny()
 }
 
-func waitForDoorToBeOpened() *time.Timer {
-	resetToLocked := time.NewTimer(openWaitDuration)
-	go checkDoor(resetToLocked, door.Open)
-	log.Println("MAIN: Scheduled resetToLocked (check=open)")
-	return resetToLocked
+// forcedOpen raises the alarm for a door opened without being unlocked
+func forcedOpen(at time.Time) {
+	log.Println("MAIN: Door forced open")
+	book.Audit(at, codebook.DoorAudit, "", codebook.Forced, "")
+	sms.SendTamper()
+	control.SendAlarm("tamper", "", at)
 }
 
-func resetToLocked() *time.Timer {
-	resetToLocked := time.NewTimer(doorOpenDuration)
-	go checkDoor(resetToLocked, door.Closed)
-	log.Println("MAIN: Scheduled resetToLocked (check=closed)")
-	return resetToLocked
+// latchFault reports a latch that keeps failing to release
+func latchFault(failures int, detail string) {
+	log.Printf("MAIN: Latch fault: %v\n", detail)
+	book.Audit(time.Now(), codebook.DoorAudit, "", codebook.LatchFailed, detail)
+	sms.SendLatchFault(detail)
+	door.Indicators().Show(door.FaultLayer, door.AlertPriority, door.FaultPattern, latchFaultDisplay)
 }
 
-func checkDoor(checkDuration *time.Timer, expectedState door.ContactState) {
-	contact := make(chan bool)
-	stop := make(chan bool)
-	defer close(contact)
-	defer close(stop)
+// keypadStatus shows a fault while the keypad is unavailable, alerting by SMS
+func keypadStatus(online bool, err error) {
+	if online {
+		log.Println("MAIN: Keypad available")
+		door.Indicators().Clear(door.KeypadLayer)
+		return
+	}
+	log.Printf("MAIN: Keypad unavailable: %v\n", err)
+	door.Indicators().Show(door.KeypadLayer, door.AlertPriority, door.FaultPattern, 0)
+	sms.SendKeypadFault(err.Error())
+}
 
-	go contactCheck(contact, stop, expectedState)
+// lockoutAlert shows the keypad is locked out, and alerts by SMS
+func lockoutAlert(until time.Time, failures int) {
+	door.Indicators().Show(door.LockoutLayer, door.StatusPriority, door.LockoutPattern, time.Until(until))
+	sms.SendLockout(until, failures)
+}
 
-	select {
-	case <-checkDuration.C:
-		log.Println("MAIN: checkDuration returned")
-		switch expectedState {
-		case door.Closed:
-			if door.State() == door.Open {
-				sms.SendDoorNotClosed()
-				log.Println("MAIN: Door not closed")
-			}
-		case door.Open:
-			if door.State() == door.Closed {
-				sms.SendDoorNotOpened()
-				log.Println("MAIN: Door never opened")
-			}
-		}
-	case <-contact:
-		unscheduleAnyEvents()
-	}
-	if expectedState == door.Open {
-		resetToLocked()
+// controlOnline shows on the keypad whether SQS can be reached
+func controlOnline(online bool) {
+	if online {
+		door.Indicators().Clear(door.OfflineLayer)
 	} else {
-		door.Lock()
+		door.Indicators().Show(door.OfflineLayer, door.StatusPriority, door.OfflinePattern, 0)
 	}
 }
 
-func contactCheck(check chan bool, stop chan bool, expectedState door.ContactState) {
-	stopped := false
-	for door.State() != expectedState && !stopped {
-		select {
-		default:
-			time.Sleep(100 * time.Millisecond)
-		case <-stop:
-			stopped = true
-			return
+// watchContact passes the door contact's edges to the controller
+func watchContact(edges <-chan door.Edge) {
+	for edge := range edges {
+		switch edge.Kind {
+		case door.DoorOpened:
+			controller.Contact(door.Open)
+		case door.DoorClosed:
+			controller.Contact(door.Closed)
+		case door.InputFault:
+			if edge.Input == door.ContactInput {
+				controller.Fault(edge.Err)
+			}
+		case door.InputRestored:
+			if edge.Input == door.ContactInput {
+				controller.Restore()
+			}
 		}
 	}
-	if expectedState == door.Open {
+}
+
+// doorChanged notifies and audits the door's transitions, and settles the
+// held use of a code once it is known whether the door opened
+func doorChanged(t door.Transition) {
+	switch {
+	case t.To == door.Opened && t.From != door.Opened:
 		log.Println("MAIN: Detected door open")
-	} else {
+		book.Audit(t.At, codebook.DoorAudit, "", codebook.Opened, "")
+		settleReservation(true)
+	case t.From == door.Unlocked && t.To == door.Idle && t.Event == door.TimedOut:
+		sms.SendDoorNotOpened()
+		log.Println("MAIN: Door never opened")
+		book.Audit(t.At, codebook.DoorAudit, "", codebook.NotOpened, "")
+		settleReservation(false)
+	case t.To == door.HeldOpen:
+		sms.SendDoorNotClosed()
+		log.Println("MAIN: Door not closed")
+		book.Audit(t.At, codebook.DoorAudit, "", codebook.NotClosed, "")
+	case (t.From == door.Opened || t.From == door.HeldOpen) && t.Event == door.ContactClosed:
 		log.Println("MAIN: Detected door close")
+		book.Audit(t.At, codebook.DoorAudit, "", codebook.Closed, "")
+	case t.To == door.Fault:
+		sms.SendDoorFault(t.Detail)
+		log.Printf("MAIN: Door fault: %v\n", t.Detail)
+		settleReservation(false)
+		door.Indicators().Show(door.FaultLayer, door.AlertPriority, door.FaultPattern, 0)
+	}
+	if t.From == door.Fault {
+		door.Indicators().Clear(door.FaultLayer)
 	}
-	check <- true
 }
 
-func resetToIdle() *time.Timer {
-	resetToIdle := time.NewTimer(resetToIdleDuration)
-	go func() {
-		<-resetToIdle.C
-		door.Lock()
-	}()
-	log.Println("MAIN: Scheduled resetToIdle")
-	return resetToIdle
+// holdReservation keeps the use of a code until the door is seen to open,
+// releasing any earlier use that was never settled
+func holdReservation(held *codebook.Reservation) {
+	reservationMu.Lock()
+	defer reservationMu.Unlock()
+	reservation.Release()
+	reservation = held
+}
+
+// settleReservation commits the held use of a code if the door was opened,
+// or returns it if not
+func settleReservation(opened bool) {
+	reservationMu.Lock()
+	defer reservationMu.Unlock()
+	if opened {
+		reservation.Commit()
+	} else {
+		reservation.Release()
+	}
+	reservation = nil
 }
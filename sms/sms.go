@@ -8,7 +8,9 @@ package sms
 
 import (
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -18,6 +20,7 @@ import (
 var sess *session.Session
 var svc *sns.SNS
 var msdns []string
+var alarmMsdns []string
 
 // Initialise x
 func Initialise(destinations []string) {
@@ -33,8 +36,35 @@ func Initialise(destinations []string) {
 	log.Println("SNS: service created")
 }
 
+// InitialiseAlarm sets the destinations for alarms, kept apart from those
+// notified of everyday use
+func InitialiseAlarm(destinations []string) {
+	alarmMsdns = destinations
+	if len(alarmMsdns) <= 0 {
+		log.Println("SNS: No alarm subscribers set")
+	}
+}
+
 func send(message string) {
-	if len(msdns) <= 0 {
+	sendTo(msdns, message)
+}
+
+func sendTo(destinations []string, message string) {
+	publishTo(destinations, message, log.Println)
+}
+
+// sendSilently sends without logging anything, not even a failure
+func sendSilently(destinations []string, message string) {
+	publishTo(destinations, message, func(...interface{}) {})
+}
+
+// publish sends an SMS through SNS
+var publish = func(params *sns.PublishInput) (*sns.PublishOutput, error) {
+	return svc.Publish(params)
+}
+
+func publishTo(destinations []string, message string, logFn func(...interface{})) {
+	if len(destinations) <= 0 {
 		return
 	}
 
@@ -49,23 +79,23 @@ func send(message string) {
 		},
 	}
 
-	for _, msdn := range msdns {
+	for _, msdn := range destinations {
 		params := &sns.PublishInput{
 			Message:           aws.String(message),
 			PhoneNumber:       aws.String(msdn),
 			MessageAttributes: attributes,
 		}
-		resp, err := svc.Publish(params)
+		resp, err := publish(params)
 
 		if err != nil {
 			// Print the error, cast err to awserr.Error to get the Code and
 			// Message from an error.
-			log.Println(err.Error())
+			logFn(err.Error())
 			return
 		}
 
 		// Pretty-print the response data.
-		log.Println(resp)
+		logFn(resp)
 	}
 }
 
@@ -81,6 +111,40 @@ func SendInvalidCode(code string) {
 	go func() { send("Invalid code entered " + code) }()
 }
 
+// SendDeniedCode alerts that a known code was denied, and why
+func SendDeniedCode(code, name, reason string) {
+	log.Printf("SMS: denied code: %v, %v\n", code, reason)
+	go func() { send("Code for " + name + " " + reason + " [" + redactCode(code) + "]") }()
+}
+
+// SendLockout alerts that the keypad is locked after invalid codes
+func SendLockout(until time.Time, failures int) {
+	log.Printf("SMS: keypad locked until: %v\n", until)
+	go func() {
+		send("Keypad locked until " + until.Format("15:04") + " after " + strconv.Itoa(failures) + " invalid codes")
+	}()
+}
+
+// SendAlarm raises an alarm without logging it
+func SendAlarm(name string) {
+	go func() { sendSilently(alarmMsdns, "ALARM: duress code entered for "+name) }()
+}
+
+// SendTamper alarms, and alerts, that the door was forced open
+func SendTamper() {
+	log.Println("SMS: door forced")
+	go func() {
+		sendTo(alarmMsdns, "ALARM: door forced open")
+		send("ALARM: door forced open")
+	}()
+}
+
+// SendLatchFault alerts that the latch is not releasing
+func SendLatchFault(detail string) {
+	log.Printf("SMS: latch fault: %v\n", detail)
+	go func() { send("Latch fault: " + detail) }()
+}
+
 // SendDoorNotClosed x
 func SendDoorNotClosed() {
 	log.Println("SMS: door still open")
@@ -93,6 +157,18 @@ func SendDoorNotOpened() {
 	go func() { send("Door wasn't opened") }()
 }
 
+// SendDoorFault alerts that the door contact cannot be read
+func SendDoorFault(detail string) {
+	log.Printf("SMS: door fault: %v\n", detail)
+	go func() { send("Door fault: " + detail) }()
+}
+
+// SendKeypadFault alerts that the keypad is unavailable
+func SendKeypadFault(detail string) {
+	log.Printf("SMS: keypad fault: %v\n", detail)
+	go func() { send("Keypad unavailable: " + detail) }()
+}
+
 // SendRescindedCode x
 func SendRescindedCode(digits *string) {
 	log.Println("SMS: code rescinded")
@@ -105,12 +181,30 @@ func SendUpdatedCode(name, digits *string) {
 	go func() { send("Code updated: " + *name + " [" + redactCode(*digits) + "]") }()
 }
 
+// SendCheckResult sends the explanation of a code check
+func SendCheckResult(result string) {
+	log.Println("SMS: check result")
+	go func() { send("Code check: " + result) }()
+}
+
 // SendOverrideOpen x
 func SendOverrideOpen(overrideType string) {
 	log.Printf("SMS: open override: %v\n", overrideType)
 	go func() { send("Door opened with override " + overrideType) }()
 }
 
+// SendOverrideHold alerts that the door is held open by an override
+func SendOverrideHold(overrideType string, d time.Duration) {
+	log.Printf("SMS: hold override: %v\n", overrideType)
+	go func() { send("Door held open for " + d.String() + " with override " + overrideType) }()
+}
+
+// SendButtonLockout alerts that the override button is disabled, e.g. stuck
+func SendButtonLockout(until time.Time) {
+	log.Printf("SMS: override button locked until: %v\n", until)
+	go func() { send("Override button disabled until " + until.Format("15:04") + ", check it is not stuck") }()
+}
+
 func redactCode(digits string) string {
 	if len(digits) < 5 {
 		return strings.Repeat("*", 4)
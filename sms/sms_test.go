@@ -1,7 +1,16 @@
 package sms
 
 import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
 )
 
 func TestRedactMoreThan5(t *testing.T) {
@@ -33,3 +42,44 @@ func TestRedactLessThan5(t *testing.T) {
 		t.Errorf("Expected ****\n")
 	}
 }
+
+func TestAlarmIsSilent(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	sent := make(chan string, 4)
+	publish = func(params *sns.PublishInput) (*sns.PublishOutput, error) {
+		sent <- *params.PhoneNumber
+		if *params.PhoneNumber == "+440000000002" {
+			return nil, errors.New("publish failed for Duress Name")
+		}
+		return &sns.PublishOutput{MessageId: aws.String("Duress Name")}, nil
+	}
+	defer func() {
+		publish = func(params *sns.PublishInput) (*sns.PublishOutput, error) { return svc.Publish(params) }
+	}()
+	InitialiseAlarm([]string{"+440000000001", "+440000000002"})
+	logged.Reset()
+
+	SendAlarm("Duress Name")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sent:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the alarm to be sent\n")
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if logged.Len() != 0 {
+		t.Errorf("Expected nothing logged, got %q\n", logged.String())
+	}
+
+	SendTamper()
+	for i := 0; i < 2; i++ {
+		<-sent
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !strings.Contains(logged.String(), "publish failed") {
+		t.Errorf("Expected other alerts to log, got %q\n", logged.String())
+	}
+}